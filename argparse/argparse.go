@@ -0,0 +1,339 @@
+package argparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Split breaks s into words like a shell would: whitespace separates words,
+// "double" and 'single' quotes group them, and a backslash escapes the next
+// character outside single quotes.
+func Split(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("missing closing %c", quote)
+	}
+	if escaped {
+		word.WriteRune('\\')
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// Quote returns s as a single word that Split gives back unchanged.
+func Quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// UsageError is returned for any parse failure. Its message ends with the
+// generated usage text so handlers can send it as-is.
+type UsageError struct {
+	Err   error
+	Usage string
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error() + "\n\n" + e.Usage
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+type flag struct {
+	name    string
+	kind    string
+	help    string
+	def     string
+	boolean bool
+	set     func(value string) error
+}
+
+type positional struct {
+	name     string
+	required bool
+	rest     bool
+}
+
+// FlagSet describes one command's options and positional arguments.
+type FlagSet struct {
+	command    string
+	flags      map[string]*flag
+	order      []*flag
+	positional []positional
+}
+
+func New(command string) *FlagSet {
+	return &FlagSet{
+		command: command,
+		flags:   make(map[string]*flag),
+	}
+}
+
+func (f *FlagSet) add(fl *flag) {
+	if _, exists := f.flags[fl.name]; exists {
+		panic("argparse: duplicate flag --" + fl.name)
+	}
+	f.flags[fl.name] = fl
+	f.order = append(f.order, fl)
+}
+
+func (f *FlagSet) Bool(name, help string) *bool {
+	value := new(bool)
+	f.add(&flag{name: name, help: help, boolean: true, set: func(string) error {
+		*value = true
+		return nil
+	}})
+	return value
+}
+
+func (f *FlagSet) String(name, def, help string) *string {
+	value := &def
+	f.add(&flag{name: name, kind: "text", help: help, def: def, set: func(s string) error {
+		*value = s
+		return nil
+	}})
+	return value
+}
+
+// Int accepts whole numbers between min and max inclusive.
+func (f *FlagSet) Int(name string, def, min, max int, help string) *int {
+	value := &def
+	f.add(&flag{name: name, kind: "n", help: fmt.Sprintf("%s (%d-%d)", help, min, max), def: strconv.Itoa(def), set: func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", s)
+		}
+		if n < min || n > max {
+			return fmt.Errorf("must be between %d and %d", min, max)
+		}
+		*value = n
+		return nil
+	}})
+	return value
+}
+
+// Duration accepts Go durations such as 30s or 2m, or a plain number of
+// seconds, between min and max inclusive.
+func (f *FlagSet) Duration(name string, def, min, max time.Duration, help string) *time.Duration {
+	value := &def
+	f.add(&flag{name: name, kind: "duration", help: fmt.Sprintf("%s (%s-%s)", help, formatDuration(min), formatDuration(max)), def: formatDuration(def), set: func(s string) error {
+		d, err := ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		if d < min || d > max {
+			return fmt.Errorf("must be between %s and %s", formatDuration(min), formatDuration(max))
+		}
+		*value = d
+		return nil
+	}})
+	return value
+}
+
+// Enum accepts one of values, case-insensitively.
+func (f *FlagSet) Enum(name, def string, values []string, help string) *string {
+	value := &def
+	f.add(&flag{name: name, kind: strings.Join(values, "|"), help: help, def: def, set: func(s string) error {
+		for _, allowed := range values {
+			if strings.EqualFold(s, allowed) {
+				*value = allowed
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+	}})
+	return value
+}
+
+// Arg declares a positional argument, only used for the usage line and the
+// missing-argument check.
+func (f *FlagSet) Arg(name string, required bool) {
+	f.positional = append(f.positional, positional{name: name, required: required})
+}
+
+// Rest declares that the remaining words form one free-text argument.
+func (f *FlagSet) Rest(name string, required bool) {
+	f.positional = append(f.positional, positional{name: name, required: required, rest: true})
+}
+
+// Parse reads flags and positional words from the text after the command
+// name. Flags may come before or after positional words; "--" ends flags.
+func (f *FlagSet) Parse(text string) ([]string, error) {
+	words, err := Split(text)
+	if err != nil {
+		return nil, f.usageError(err)
+	}
+
+	var rest []string
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+
+		if word == "--" {
+			rest = append(rest, words[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(word, "--") || len(word) == 2 {
+			rest = append(rest, word)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(word, "--"), "=")
+		fl, ok := f.flags[strings.ToLower(name)]
+		if !ok {
+			return nil, f.usageError(fmt.Errorf("unknown option --%s", name))
+		}
+
+		if fl.boolean {
+			if hasValue {
+				return nil, f.usageError(fmt.Errorf("--%s takes no value", fl.name))
+			}
+			fl.set("")
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(words) {
+				return nil, f.usageError(fmt.Errorf("--%s needs a value", fl.name))
+			}
+			i++
+			value = words[i]
+		}
+
+		if err := fl.set(value); err != nil {
+			return nil, f.usageError(fmt.Errorf("--%s: %w", fl.name, err))
+		}
+	}
+
+	if err := f.checkPositional(rest); err != nil {
+		return nil, f.usageError(err)
+	}
+
+	return rest, nil
+}
+
+func (f *FlagSet) checkPositional(words []string) error {
+	hasRest := false
+	for i, arg := range f.positional {
+		if arg.rest {
+			hasRest = true
+		}
+		if arg.required && len(words) <= i {
+			return fmt.Errorf("missing <%s>", arg.name)
+		}
+	}
+
+	if !hasRest && len(words) > len(f.positional) {
+		return fmt.Errorf("unexpected argument %q", words[len(f.positional)])
+	}
+	return nil
+}
+
+func (f *FlagSet) usageError(err error) error {
+	return &UsageError{Err: err, Usage: f.Usage()}
+}
+
+// Usage lists the command line followed by one line per option.
+func (f *FlagSet) Usage() string {
+	var usage strings.Builder
+	usage.WriteString("Usage: /" + f.command)
+
+	for _, fl := range f.order {
+		if fl.boolean {
+			usage.WriteString(" [--" + fl.name + "]")
+		} else {
+			usage.WriteString(" [--" + fl.name + " " + fl.kind + "]")
+		}
+	}
+	for _, arg := range f.positional {
+		name := "<" + arg.name + ">"
+		if arg.rest {
+			name = "<" + arg.name + "...>"
+		}
+		if !arg.required {
+			name = "[" + name + "]"
+		}
+		usage.WriteString(" " + name)
+	}
+
+	for _, fl := range f.order {
+		usage.WriteString("\n  --" + fl.name + "  " + fl.help)
+		if fl.def != "" {
+			usage.WriteString(", default " + fl.def)
+		}
+	}
+
+	return usage.String()
+}
+
+// ParseDuration is time.ParseDuration that also takes a bare number as
+// seconds.
+func ParseDuration(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration (try 30s or 2m)", s)
+	}
+	return d, nil
+}
+
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
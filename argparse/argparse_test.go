@@ -0,0 +1,141 @@
+package argparse
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr string
+	}{
+		{"", nil, ""},
+		{"  a  b\tc\n", []string{"a", "b", "c"}, ""},
+		{`"two words" one`, []string{"two words", "one"}, ""},
+		{`'single "inner"'`, []string{`single "inner"`}, ""},
+		{`a"b c"d`, []string{"ab cd"}, ""},
+		{`""`, []string{""}, ""},
+		{`back\ slash`, []string{"back slash"}, ""},
+		{`"esc \" quote"`, []string{`esc " quote`}, ""},
+		{`'no \ escape'`, []string{`no \ escape`}, ""},
+		{`trailing\`, []string{`trailing\`}, ""},
+		{"don't", nil, "missing closing '"},
+		{`"open`, nil, `missing closing "`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Split(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteRoundTrip(t *testing.T) {
+	for _, s := range []string{"", "plain", "two words", `say "hi"`, `back\slash`, "it's"} {
+		words, err := Split(Quote(s))
+		if err != nil {
+			t.Fatalf("Split(Quote(%q)): %v", s, err)
+		}
+		if len(words) != 1 || words[0] != s {
+			t.Errorf("Split(Quote(%q)) = %q", s, words)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     string
+		want     []string
+		limit    int
+		sort     string
+		duration time.Duration
+		verbose  bool
+		wantErr  string
+	}{
+		{name: "defaults", args: "chrome", want: []string{"chrome"}, limit: 10, sort: "cpu", duration: 5 * time.Second},
+		{name: "flags anywhere", args: "--limit 3 chrome --verbose", want: []string{"chrome"}, limit: 3, sort: "cpu", duration: 5 * time.Second, verbose: true},
+		{name: "equals form", args: "--limit=4 --sort=MEM x", want: []string{"x"}, limit: 4, sort: "mem", duration: 5 * time.Second},
+		{name: "bare seconds", args: "--duration 30 x", want: []string{"x"}, limit: 10, sort: "cpu", duration: 30 * time.Second},
+		{name: "double dash", args: "-- --limit", want: []string{"--limit"}, limit: 10, sort: "cpu", duration: 5 * time.Second},
+		{name: "unknown flag", args: "--nope x", wantErr: "unknown option --nope"},
+		{name: "out of range", args: "--limit 0 x", wantErr: "--limit: must be between 1 and 50"},
+		{name: "not a number", args: "--limit many x", wantErr: `--limit: "many" is not a number`},
+		{name: "missing value", args: "x --limit", wantErr: "--limit needs a value"},
+		{name: "bool with value", args: "--verbose=yes x", wantErr: "--verbose takes no value"},
+		{name: "bad enum", args: "--sort disk x", wantErr: "--sort: must be one of cpu, mem"},
+		{name: "bad duration", args: "--duration soon x", wantErr: `"soon" is not a duration`},
+		{name: "missing positional", args: "--limit 2", wantErr: "missing <name>"},
+		{name: "extra positional", args: "a b", wantErr: `unexpected argument "b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := New("test")
+			limit := flags.Int("limit", 10, 1, 50, "how many")
+			sort := flags.Enum("sort", "cpu", []string{"cpu", "mem"}, "order")
+			duration := flags.Duration("duration", 5*time.Second, time.Second, time.Minute, "length")
+			verbose := flags.Bool("verbose", "more output")
+			flags.Arg("name", true)
+
+			got, err := flags.Parse(tt.args)
+			if tt.wantErr != "" {
+				var usage *UsageError
+				if !errors.As(err, &usage) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want a usage error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || *limit != tt.limit || *sort != tt.sort || *duration != tt.duration || *verbose != tt.verbose {
+				t.Errorf("got %q limit=%d sort=%s duration=%s verbose=%v", got, *limit, *sort, *duration, *verbose)
+			}
+		})
+	}
+}
+
+func TestParseRest(t *testing.T) {
+	flags := New("test")
+	flags.Arg("first", true)
+	flags.Rest("rest", false)
+
+	got, err := flags.Parse(`one two "three four"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"one", "two", "three four"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	flags := New("kill")
+	flags.Bool("force", "skip confirmation")
+	flags.Int("wait", 5, 0, 60, "seconds to wait")
+	flags.Arg("pid", true)
+	flags.Rest("note", false)
+
+	want := "Usage: /kill [--force] [--wait n] <pid> [<note...>]\n" +
+		"  --force  skip confirmation\n" +
+		"  --wait  seconds to wait (0-60), default 5"
+	if got := flags.Usage(); got != want {
+		t.Errorf("Usage() =\n%s\nwant\n%s", got, want)
+	}
+}
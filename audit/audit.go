@@ -0,0 +1,268 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OutcomeOK        = "ok"
+	OutcomeFailed    = "failed"
+	OutcomeCancelled = "cancelled"
+	OutcomeDenied    = "denied"
+	OutcomeRejected  = "rejected"
+)
+
+type Entry struct {
+	Time       time.Time `json:"time"`
+	UserID     int64     `json:"user_id"`
+	UserName   string    `json:"user_name"`
+	ChatID     int64     `json:"chat_id"`
+	Command    string    `json:"command"`
+	Args       string    `json:"args,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	Via        string    `json:"via,omitempty"`
+}
+
+func (e Entry) String() string {
+	text := fmt.Sprintf("%s  %s (%d)  /%s", e.Time.Format("2006-01-02 15:04:05"), e.UserName, e.UserID, e.Command)
+	if e.Args != "" {
+		text += " " + e.Args
+	}
+	if e.Via != "" {
+		text += "  via " + e.Via
+	}
+	text += fmt.Sprintf("  %s %dms", e.Outcome, e.DurationMs)
+	if e.Error != "" {
+		text += ": " + e.Error
+	}
+	return text
+}
+
+// Filter narrows a query. Zero fields match everything; User matches either
+// the numeric ID or a case-insensitive name.
+type Filter struct {
+	User  string
+	Since time.Time
+	Limit int
+}
+
+func (f Filter) matches(e Entry) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if f.User == "" {
+		return true
+	}
+	if id, err := strconv.ParseInt(f.User, 10, 64); err == nil {
+		return e.UserID == id
+	}
+	return strings.EqualFold(strings.TrimPrefix(f.User, "@"), e.UserName)
+}
+
+// Log is an append-only JSONL file. When it grows past maxBytes it is renamed
+// to path.1 (older files shift up) and at most keep old files are kept.
+//
+// Queries read the files without holding up writes. A rotation that falls
+// due while a query is reading waits for a later write, so the files
+// don't move under the reader.
+type Log struct {
+	mu       sync.Mutex
+	files    sync.RWMutex
+	path     string
+	maxBytes int64
+	keep     int
+	file     *os.File
+	size     int64
+}
+
+func Open(path string, maxBytes int64, keep int) (*Log, error) {
+	l := &Log{
+		path:     path,
+		maxBytes: maxBytes,
+		keep:     keep,
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+func (l *Log) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+
+	if l.size > 0 && l.size+int64(len(line)) > l.maxBytes && l.files.TryLock() {
+		err := l.rotate()
+		l.files.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+
+	os.Remove(l.rotatedPath(l.keep))
+	for i := l.keep - 1; i >= 1; i-- {
+		os.Rename(l.rotatedPath(i), l.rotatedPath(i+1))
+	}
+	if l.keep > 0 {
+		if err := os.Rename(l.path, l.rotatedPath(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return err
+	}
+
+	return l.open()
+}
+
+func (l *Log) rotatedPath(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// Query returns matching entries oldest first. With a limit, only the most
+// recent matches are returned.
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	l.files.RLock()
+	defer l.files.RUnlock()
+
+	var entries []Entry
+
+	for i := l.keep; i >= 0; i-- {
+		path := l.path
+		if i > 0 {
+			path = l.rotatedPath(i)
+		}
+
+		found, err := readEntries(path, filter)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		entries = append(entries, found...)
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+
+	return entries, nil
+}
+
+func readEntries(path string, filter Filter) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		var entry Entry
+		// A torn last line after a crash shouldn't hide the rest of the log.
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// ParseFilter reads "[user] [since]" in either order. since is a duration
+// back from now ("2h", "7d") or a date ("2006-01-02").
+func ParseFilter(args []string) (Filter, error) {
+	var filter Filter
+
+	for _, arg := range args {
+		if since, ok := parseSince(arg); ok {
+			filter.Since = since
+			continue
+		}
+		if filter.User != "" {
+			return filter, fmt.Errorf("unexpected argument %q", arg)
+		}
+		filter.User = arg
+	}
+
+	return filter, nil
+}
+
+func parseSince(arg string) (time.Time, bool) {
+	if days, ok := strings.CutSuffix(arg, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n), true
+		}
+	}
+	if d, err := time.ParseDuration(arg); err == nil {
+		return time.Now().Add(-d), true
+	}
+	if t, err := time.ParseInLocation("2006-01-02", arg, time.Local); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,187 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestLog(t *testing.T, maxBytes int64, keep int) (*Log, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path, maxBytes, keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, path
+}
+
+func TestQuery(t *testing.T) {
+	l, _ := openTestLog(t, 1<<20, 2)
+
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{UserID: 1, UserName: "ann", Command: "info"},
+		{UserID: 2, UserName: "bob", Command: "ss"},
+		{UserID: 1, UserName: "ann", Command: "kill", Args: "42"},
+		{UserID: 2, UserName: "bob", Command: "processes"},
+	} {
+		e.Time = base.Add(time.Duration(i) * time.Hour)
+		e.Outcome = OutcomeOK
+		if err := l.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"all", Filter{}, []string{"info", "ss", "kill", "processes"}},
+		{"by id", Filter{User: "1"}, []string{"info", "kill"}},
+		{"by name", Filter{User: "@BOB"}, []string{"ss", "processes"}},
+		{"since", Filter{Since: base.Add(90 * time.Minute)}, []string{"kill", "processes"}},
+		{"limit keeps the newest", Filter{Limit: 3}, []string{"ss", "kill", "processes"}},
+		{"no match", Filter{User: "carol"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Command)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	l, path := openTestLog(t, 150, 2)
+
+	for i := 0; i < 10; i++ {
+		if err := l.Write(Entry{Time: time.Now(), UserID: int64(i), Command: "info", Outcome: OutcomeOK}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s: %v", filepath.Base(p), err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("kept more than 2 old files")
+	}
+
+	entries, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || entries[len(entries)-1].UserID != 9 {
+		t.Errorf("newest entry missing: %+v", entries)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].UserID <= entries[i-1].UserID {
+			t.Fatalf("entries out of order: %+v", entries)
+		}
+	}
+}
+
+func TestRotationWaitsForReaders(t *testing.T) {
+	l, path := openTestLog(t, 100, 1)
+
+	l.files.RLock()
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		for i := 0; i < 5 && err == nil; i++ {
+			err = l.Write(Entry{Time: time.Now(), Command: "info", Outcome: OutcomeOK})
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes blocked behind a reader")
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Fatal("rotated while a reader held the files")
+	}
+	l.files.RUnlock()
+
+	if err := l.Write(Entry{Time: time.Now(), Command: "info", Outcome: OutcomeOK}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("postponed rotation did not happen: %v", err)
+	}
+}
+
+func TestTornLineIsSkipped(t *testing.T) {
+	l, path := openTestLog(t, 1<<20, 1)
+	l.Write(Entry{Time: time.Now(), Command: "info", Outcome: OutcomeOK})
+	l.Close()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2026-01-02T1`)
+	f.Close()
+
+	entries, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d entries, want 1", len(entries))
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		args    []string
+		user    string
+		since   bool
+		wantErr bool
+	}{
+		{nil, "", false, false},
+		{[]string{"123"}, "123", false, false},
+		{[]string{"2h"}, "", true, false},
+		{[]string{"7d", "ann"}, "ann", true, false},
+		{[]string{"ann", "2026-01-02"}, "ann", true, false},
+		{[]string{"ann", "bob"}, "", false, true},
+	}
+	for _, tt := range tests {
+		filter, err := ParseFilter(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFilter(%q) error = %v", tt.args, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if filter.User != tt.user || filter.Since.IsZero() == tt.since {
+			t.Errorf("ParseFilter(%q) = %+v", tt.args, filter)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"remoteadmin/argparse"
+	"remoteadmin/audit"
+	"remoteadmin/commands"
+	"remoteadmin/totp"
+	"remoteadmin/transport"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	apiVia = "api"
+
+	totpHeader = "X-TOTP-Code"
+
+	maxAPIBodyBytes = 64 * 1024
+)
+
+type apiMessage struct {
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+type apiResult struct {
+	Command   string                 `json:"command"`
+	Outcome   string                 `json:"outcome"`
+	Error     string                 `json:"error,omitempty"`
+	Messages  []apiMessage           `json:"messages"`
+	Processes []commands.ProcessInfo `json:"processes,omitempty"`
+
+	files   []transport.RecordedFile
+	buttons []string
+}
+
+// apiCall is one authenticated HTTP request, from the API or the
+// dashboard. Every command it runs replies to the same routed chat, so
+// follow-up steps such as a confirmation land in the same recorder.
+type apiCall struct {
+	userID   int64
+	userName string
+	via      string
+	chatID   int64
+	recorder *transport.Recorder
+}
+
+// newCall attaches a recorder for one request. Call detach when the
+// response has been written.
+func (b *Bot) newCall(userID int64, userName, via string) (call *apiCall, detach func()) {
+	call = &apiCall{
+		userID:   userID,
+		userName: userName,
+		via:      via,
+		recorder: transport.NewRecorder(),
+	}
+	call.chatID, detach = b.replies.Attach(call.recorder)
+	return call, detach
+}
+
+type apiHandler func(w http.ResponseWriter, r *http.Request, call *apiCall)
+
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// startAPI serves the local REST API. Every request runs through the same
+// registry, role checks, two-factor codes and audit log as Telegram; only
+// the replies are collected and returned instead of sent.
+func (b *Bot) startAPI() error {
+	cfg := b.config.API
+	if !cfg.Enabled {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/info", b.apiAuth(b.apiInfo))
+	mux.HandleFunc("GET /api/processes", b.apiAuth(b.apiProcesses))
+	mux.HandleFunc("POST /api/processes/{pid}/kill", b.apiAuth(b.apiKill))
+	mux.HandleFunc("GET /api/screenshot", b.apiAuth(b.apiScreenshot))
+	mux.HandleFunc("POST /api/browser/{action}", b.apiAuth(b.apiBrowser))
+	mux.HandleFunc("POST /api/message", b.apiAuth(b.apiMessage))
+
+	b.apiServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		var err error
+		if cfg.CertFile != "" {
+			err = b.apiServer.ServeTLS(listener, cfg.CertFile, cfg.KeyFile)
+		} else {
+			err = b.apiServer.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("api listener stopped", "error", err)
+		}
+	}()
+
+	slog.Info("api listening", "addr", cfg.ListenAddr, "tls", cfg.CertFile != "")
+	return nil
+}
+
+func (b *Bot) stopAPI() {
+	if b.apiServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := b.apiServer.Shutdown(ctx); err != nil {
+		slog.Warn("failed to stop api listener", "error", err)
+	}
+}
+
+// apiAuth resolves the bearer token to the user it acts as. Unknown tokens
+// are audited like unauthorized Telegram users.
+func (b *Bot) apiAuth(next apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		for _, caller := range b.config.API.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(caller.Token)) == 1 {
+				call, detach := b.newCall(caller.UserID, caller.Name, apiVia)
+				defer detach()
+
+				next(w, r, call)
+				return
+			}
+		}
+
+		slog.Warn("rejected api request", "remote", r.RemoteAddr, "method", r.Method, "path", r.URL.Path)
+		b.record(&commands.Request{
+			Command: "unauthorized",
+			Args:    r.Method + " " + r.URL.Path + " from " + r.RemoteAddr,
+			Via:     apiVia,
+		}, audit.OutcomeRejected, nil, time.Now())
+
+		writeAPIError(w, &apiError{Status: http.StatusUnauthorized, Message: "invalid or missing token"})
+	}
+}
+
+func (b *Bot) apiInfo(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	result, err := b.apiRun(r, call, "info", "", false)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (b *Bot) apiProcesses(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	var args []string
+	if sortBy := r.URL.Query().Get("sort"); sortBy != "" {
+		args = append(args, "--sort", argparse.Quote(sortBy))
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		args = append(args, "--limit", argparse.Quote(limit))
+	}
+
+	result, err := b.apiRun(r, call, "processes", strings.Join(args, " "), false)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	if processes, err := b.processHandler.GetProcessList(); err == nil {
+		result.Processes = processes
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apiKill runs /kill and, since the call is itself the explicit request,
+// answers the confirmation prompt on the caller's behalf. It answers 422
+// when /kill did not get as far as asking.
+func (b *Bot) apiKill(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	result, err := b.apiRun(r, call, "kill", argparse.Quote(r.PathValue("pid")), false)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	payload, ok := confirmPayload(result.buttons)
+	if !ok {
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	confirmed, err := b.apiRun(r, call, "confirm", payload, true)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	confirmed.Command = result.Command
+	writeJSON(w, http.StatusOK, confirmed)
+}
+
+// apiScreenshot returns the PNG itself. monitor is "main" (default), "all"
+// for one combined image, or a 1-based monitor number.
+func (b *Bot) apiScreenshot(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	var result *apiResult
+	var err error
+
+	switch monitor := r.URL.Query().Get("monitor"); monitor {
+	case "", "main":
+		result, err = b.apiRun(r, call, "ssm", "", false)
+	case "all":
+		result, err = b.apiRun(r, call, "ssa", "", false)
+	default:
+		n, convErr := strconv.Atoi(monitor)
+		if convErr != nil || n < 1 {
+			writeAPIError(w, &apiError{Status: http.StatusBadRequest, Message: "monitor must be main, all or a monitor number"})
+			return
+		}
+		result, err = b.apiRun(r, call, "ss", strconv.Itoa(n-1), true)
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	for _, file := range result.files {
+		if file.Kind == transport.KindPhoto {
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", strconv.Itoa(len(file.Data)))
+			w.Write(file.Data)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusInternalServerError, result)
+}
+
+func (b *Bot) apiBrowser(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	result, err := b.apiRun(r, call, "browser", argparse.Quote(r.PathValue("action")), false)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (b *Bot) apiMessage(w http.ResponseWriter, r *http.Request, call *apiCall) {
+	var body struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodyBytes)).Decode(&body); err != nil {
+		writeAPIError(w, &apiError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid body: %v", err)})
+		return
+	}
+
+	result, err := b.apiRun(r, call, "msg", body.Text, false)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// apiRun dispatches one command as the caller and waits for it to finish.
+// The result holds everything the call's handlers have sent so far.
+func (b *Bot) apiRun(r *http.Request, call *apiCall, name, args string, callback bool) (*apiResult, error) {
+	cmd, ok := b.registry.Lookup(name)
+	if !ok {
+		return nil, &apiError{Status: http.StatusNotFound, Message: fmt.Sprintf("unknown command %q", name)}
+	}
+
+	run := cmd.Handle
+	text := strings.TrimSpace("/" + name + " " + args)
+	if callback {
+		handler, ok := cmd.(commands.CallbackHandler)
+		if !ok {
+			return nil, &apiError{Status: http.StatusNotFound, Message: fmt.Sprintf("/%s has no buttons", name)}
+		}
+		run = handler.HandleCallback
+		text = commands.CallbackData(name, args)
+	}
+
+	req := &commands.Request{
+		Context:  r.Context(),
+		ChatID:   call.chatID,
+		UserID:   call.userID,
+		UserName: call.userName,
+		Command:  cmd.Info().Name,
+		Args:     args,
+		Text:     text,
+		Via:      call.via,
+	}
+
+	if !b.canRun(call.userID, cmd) {
+		b.record(req, audit.OutcomeDenied, nil, time.Now())
+		return nil, &apiError{Status: http.StatusForbidden, Message: "no access"}
+	}
+
+	if b.authenticator.Required(call.userID, req.Command) {
+		err := errCodeRequired
+		if code := r.Header.Get(totpHeader); code != "" {
+			err = b.authenticator.Verify(call.userID, code)
+		}
+		if err != nil {
+			b.record(req, audit.OutcomeDenied, err, time.Now())
+			status := http.StatusForbidden
+			if errors.Is(err, totp.ErrLockedOut) {
+				status = http.StatusTooManyRequests
+			}
+			return nil, &apiError{
+				Status:  status,
+				Code:    "totp_required",
+				Message: fmt.Sprintf("/%s: %v (send it in the %s header)", req.Command, err, totpHeader),
+			}
+		}
+	}
+
+	err, waitErr := b.dispatchAndWait(cmd, req, run, b.config.APITimeout())
+	switch {
+	case errors.Is(waitErr, errBusy):
+		return nil, &apiError{Status: http.StatusServiceUnavailable, Message: waitErr.Error()}
+	case errors.Is(waitErr, errTimeout):
+		return nil, &apiError{Status: http.StatusGatewayTimeout, Message: fmt.Sprintf("/%s did not finish in %s", req.Command, b.config.APITimeout())}
+	case errors.Is(waitErr, context.Canceled):
+		return nil, &apiError{Status: http.StatusConflict, Message: fmt.Sprintf("/%s was cancelled", req.Command)}
+	case waitErr != nil:
+		// Whatever the recorder holds is partial; don't pass it off as
+		// the result.
+		return nil, &apiError{Status: http.StatusInternalServerError, Message: fmt.Sprintf("/%s: %v", req.Command, waitErr)}
+	}
+
+	result := &apiResult{
+		Command: req.Command,
+		Outcome: outcomeOf(req, err),
+		files:   call.recorder.Files(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	for _, msg := range call.recorder.Messages() {
+		result.Messages = append(result.Messages, apiMessage{Text: msg.Text, ParseMode: msg.ParseMode})
+		for _, row := range msg.Keyboard {
+			for _, button := range row {
+				result.buttons = append(result.buttons, button.Data)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// confirmPayload finds the Confirm button of a confirmation prompt.
+func confirmPayload(buttons []string) (string, bool) {
+	for _, data := range buttons {
+		name, payload, ok := commands.ParseCallbackData(data)
+		if ok && name == "confirm" && strings.HasSuffix(payload, ":yes") {
+			return payload, true
+		}
+	}
+	return "", false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, err error) {
+	body := map[string]string{"error": err.Error()}
+	status := http.StatusInternalServerError
+
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		status = apiErr.Status
+		if apiErr.Code != "" {
+			body["code"] = apiErr.Code
+		}
+	}
+	writeJSON(w, status, body)
+}
@@ -0,0 +1,29 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"remoteadmin/config"
+	"strings"
+	"testing"
+)
+
+func TestAPIMessageKeepsTextAsTyped(t *testing.T) {
+	b, _ := newTestBot(t, &config.Config{})
+	console := &popups{}
+	b.SetConsoleHandler(console)
+
+	body := strings.NewReader(`{"text": "say \"hi\" C:\\tmp"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/message", body)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	w := httptest.NewRecorder()
+	b.apiAuth(b.apiMessage)(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	want := `tester: say "hi" C:\tmp`
+	if len(console.shown) != 1 || console.shown[0] != want {
+		t.Errorf("popups = %q, want [%q]", console.shown, want)
+	}
+}
@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"context"
+	"log/slog"
+	"remoteadmin/audit"
+	"remoteadmin/commands"
+	"remoteadmin/metrics"
+	"time"
+)
+
+// redactedArgs stands in for the arguments of commands with SecretArgs.
+const redactedArgs = "[redacted]"
+
+func (b *Bot) record(req *commands.Request, outcome string, err error, started time.Time) {
+	entry := audit.Entry{
+		Time:       started,
+		UserID:     req.UserID,
+		UserName:   req.UserName,
+		ChatID:     req.ChatID,
+		Command:    req.Command,
+		Args:       req.Args,
+		Outcome:    outcome,
+		DurationMs: time.Since(started).Milliseconds(),
+		Via:        req.Via,
+	}
+	if b.replies.Issued(req.ChatID) {
+		entry.ChatID = 0
+	}
+	if cmd, ok := b.registry.Lookup(req.Command); ok && cmd.Info().SecretArgs && entry.Args != "" {
+		entry.Args = redactedArgs
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	metrics.Commands.Inc(entry.Command, outcome)
+	switch outcome {
+	case audit.OutcomeOK, audit.OutcomeFailed, audit.OutcomeCancelled:
+		metrics.CommandDuration.Observe(time.Since(started).Seconds(), entry.Command)
+	}
+
+	level := slog.LevelInfo
+	if outcome != audit.OutcomeOK {
+		level = slog.LevelWarn
+	}
+	slog.Log(context.Background(), level, "command",
+		"command", entry.Command,
+		"args", entry.Args,
+		"user_id", entry.UserID,
+		"user", entry.UserName,
+		"chat_id", entry.ChatID,
+		"outcome", entry.Outcome,
+		"duration", time.Duration(entry.DurationMs)*time.Millisecond,
+		"via", entry.Via,
+		"error", entry.Error)
+
+	if err := b.audit.Write(entry); err != nil {
+		slog.Error("failed to write audit log", "error", err)
+	}
+}
+
+func outcomeOf(req *commands.Request, err error) string {
+	switch {
+	case req.Context.Err() != nil:
+		return audit.OutcomeCancelled
+	case err != nil:
+		return audit.OutcomeFailed
+	default:
+		return audit.OutcomeOK
+	}
+}
+
+func (b *Bot) AuditLog() *audit.Log {
+	return b.audit
+}
@@ -1,9 +1,24 @@
 package bot
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"remoteadmin/audit"
 	"remoteadmin/commands"
 	"remoteadmin/config"
-	"strings"
+	"remoteadmin/dashboard"
+	"remoteadmin/fleet"
+	"remoteadmin/guard"
+	"remoteadmin/jobs"
+	"remoteadmin/outbox"
+	"remoteadmin/queue"
+	"remoteadmin/schedule"
+	"remoteadmin/totp"
+	"remoteadmin/transport"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -11,7 +26,13 @@ import (
 
 type Bot struct {
 	api               *tgbotapi.BotAPI
+	telegram          *transport.Telegram
+	messenger         *transport.RateLimited
+	replies           *transport.Router
 	config            *config.Config
+	registry          *commands.Registry
+	pool              *queue.Pool
+	jobs              *jobs.Manager
 	startTime         time.Time
 	infoHandler       *commands.InfoHandler
 	messageHandler    *commands.MessageHandler
@@ -23,6 +44,29 @@ type Bot struct {
 	helpHandler       *commands.HelpHandler
 	fileHandler       *commands.FileHandler
 	browserKiller     *commands.BrowserKiller
+	jobsHandler       *commands.JobsHandler
+	confirmer         *commands.Confirmer
+	guard             *guard.Guard
+	securityHandler   *commands.SecurityHandler
+	authenticator     *totp.Authenticator
+	twoFactorHandler  *commands.TwoFactorHandler
+	held              heldCommands
+	audit             *audit.Log
+	auditHandler      *commands.AuditHandler
+	webhook           *webhookServer
+	metricsServer     *http.Server
+	apiServer         *http.Server
+	dashboardServer   *http.Server
+	sessions          *dashboard.Sessions
+	loginFailures     *guard.Limiter
+	scheduler         *schedule.Scheduler
+	scheduleHandler   *commands.ScheduleHandler
+	outbox            *outbox.Outbox
+	fleet             *fleet.Coordinator
+	fleetAgent        *fleet.Agent
+	fleetHandler      *commands.FleetHandler
+	agentStop         chan struct{}
+	shutdownOnce      sync.Once
 	consoleHandler    interface {
 		SendPopup(message string)
 	}
@@ -36,29 +80,194 @@ func NewBot(cfg *config.Config) (*Bot, error) {
 
 	bot.Debug = false
 
-	return &Bot{
+	registry := commands.NewRegistry()
+	telegram := transport.NewTelegram(bot)
+
+	// An agent never talks to Telegram itself; everything goes through
+	// the coordinator.
+	var outgoing transport.Messenger = telegram
+	var agent *fleet.Agent
+	if cfg.Fleet.Role == config.FleetAgent {
+		tlsConfig, err := agentTLSConfig(cfg.Fleet)
+		if err != nil {
+			return nil, fmt.Errorf("fleet tls: %w", err)
+		}
+		agent = fleet.NewAgent(cfg.Fleet.CoordinatorAddr, cfg.Fleet.Name, cfg.Fleet.Secret, tlsConfig)
+		outgoing = agent
+	}
+
+	messenger := transport.NewRateLimited(outgoing, transport.Limits{
+		ChatInterval:   time.Duration(cfg.SendLimits.ChatIntervalMs) * time.Millisecond,
+		GlobalInterval: time.Second / time.Duration(cfg.SendLimits.GlobalPerSecond),
+		MaxRetries:     cfg.SendLimits.MaxRetries,
+	})
+	// Handlers reply through the splitter so long output never hits
+	// Telegram's message size limit. The router in front of it hands
+	// replies for API requests back to the caller instead. In a fleet,
+	// replies say which host sent them; the coordinator splits and labels
+	// agents' replies as it passes them on.
+	var handlerOut transport.Messenger = transport.NewSplitter(messenger)
+	switch cfg.Fleet.Role {
+	case config.FleetCoordinator:
+		handlerOut = transport.NewLabeled(handlerOut, cfg.Fleet.Name)
+	case config.FleetAgent:
+		handlerOut = messenger
+	}
+	replies := transport.NewRouter(handlerOut)
+	jobManager := jobs.NewManager()
+	notifications := outbox.New(replies, cfg.Outbox.File, cfg.OutboxMaxAge())
+	confirmer := commands.NewConfirmer(replies, cfg.ConfirmTimeout())
+	accessGuard := guard.New(cfg.Unauthorized, "banned_users.json")
+	auditLog, err := audit.Open(cfg.Audit.Path, int64(cfg.Audit.MaxSizeMB)*1024*1024, cfg.Audit.MaxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+
+	b := &Bot{
 		api:               bot,
+		telegram:          telegram,
+		messenger:         messenger,
+		replies:           replies,
 		config:            cfg,
+		registry:          registry,
+		pool:              queue.NewPool(cfg.WorkerCount, cfg.MaxHeavyJobs),
+		jobs:              jobManager,
 		startTime:         time.Now(),
-		infoHandler:       commands.NewInfoHandler(bot, cfg, time.Now()),
-		messageHandler:    commands.NewMessageHandler(bot, cfg),
+		infoHandler:       commands.NewInfoHandler(replies, cfg, time.Now(), telegram.UserName()),
+		messageHandler:    commands.NewMessageHandler(replies, cfg, notifications),
 		msgHandler:        nil,
-		processHandler:    commands.NewProcessHandler(bot),
-		screenshotHandler: commands.NewScreenshotHandler(bot),
-		videoHandler:      commands.NewVideoHandler(bot),
-		audioHandler:      commands.NewAudioHandler(bot),
-		helpHandler:       commands.NewHelpHandler(bot),
-		fileHandler:       commands.NewFileHandler(bot),
-		browserKiller:     commands.NewBrowserKiller(bot, cfg),
-	}, nil
+		processHandler:    commands.NewProcessHandler(replies, confirmer),
+		screenshotHandler: commands.NewScreenshotHandler(replies),
+		videoHandler:      commands.NewVideoHandler(replies),
+		audioHandler:      commands.NewAudioHandler(replies),
+		helpHandler:       commands.NewHelpHandler(replies, registry, cfg),
+		fileHandler:       commands.NewFileHandler(replies, telegram),
+		browserKiller:     commands.NewBrowserKiller(replies, cfg, notifications),
+		jobsHandler:       commands.NewJobsHandler(replies, jobManager),
+		confirmer:         confirmer,
+		guard:             accessGuard,
+		securityHandler:   commands.NewSecurityHandler(replies, accessGuard),
+		authenticator:     totp.NewAuthenticator(cfg),
+		held:              heldCommands{commands: make(map[int64]heldCommand)},
+		audit:             auditLog,
+		auditHandler:      commands.NewAuditHandler(replies, auditLog),
+		scheduler:         schedule.New(cfg.Schedules),
+		outbox:            notifications,
+		fleetAgent:        agent,
+		agentStop:         make(chan struct{}),
+	}
+	b.twoFactorHandler = commands.NewTwoFactorHandler(replies, b.authenticator, "remoteadmin", b.resumeHeld)
+	b.scheduleHandler = commands.NewScheduleHandler(replies, b.scheduler, cfg, b.checkScheduled)
+	b.registerCommands()
+	if cfg.Fleet.Role == config.FleetCoordinator {
+		b.fleet = fleet.NewCoordinator(cfg.Fleet.Secret, func(host string) transport.Messenger {
+			return transport.NewLabeled(transport.NewSplitter(messenger), host)
+		})
+		b.fleetHandler = commands.NewFleetHandler(replies, b.fleet, cfg.Fleet.Name)
+		b.registerFleetCommands()
+	}
+	if err := b.registerMacros(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *Bot) Register(cmd commands.Command) error {
+	return b.registry.Register(cmd)
+}
+
+func (b *Bot) Registry() *commands.Registry {
+	return b.registry
 }
 
 func (b *Bot) Start() error {
-	updates, err := b.api.GetUpdates(tgbotapi.NewUpdate(0))
+	// The coordinator owns the menu in a fleet.
+	if b.fleetAgent == nil {
+		if err := b.syncCommandMenu(); err != nil {
+			slog.Warn("failed to update command menu", "error", err)
+		}
+	}
+
+	b.outbox.Start()
+
+	if err := b.startMetrics(); err != nil {
+		return fmt.Errorf("start metrics listener: %w", err)
+	}
+
+	if err := b.startAPI(); err != nil {
+		return fmt.Errorf("start api listener: %w", err)
+	}
+
+	if err := b.startDashboard(); err != nil {
+		return fmt.Errorf("start dashboard listener: %w", err)
+	}
+
+	if err := b.startFleet(); err != nil {
+		return fmt.Errorf("start fleet listener: %w", err)
+	}
+
+	b.scheduler.Start(b.runScheduled)
+
+	if b.fleetAgent != nil {
+		return b.runAgent()
+	}
+
+	var updateChan tgbotapi.UpdatesChannel
+	var err error
+
+	if b.config.Mode == config.ModeWebhook {
+		updateChan, err = b.startWebhook()
+	} else {
+		updateChan, err = b.startPolling()
+	}
 	if err != nil {
 		return err
 	}
 
+	// Polling ends by closing its channel; the webhook closes stopped.
+	var stopped <-chan struct{}
+	if b.webhook != nil {
+		stopped = b.webhook.stopped
+	}
+
+	for {
+		select {
+		case update, ok := <-updateChan:
+			if !ok {
+				return nil
+			}
+			b.handleUpdate(update)
+		case <-stopped:
+			return nil
+		}
+	}
+}
+
+func (b *Bot) handleUpdate(update tgbotapi.Update) {
+	if b.fleet != nil && b.forwardUpdate(update) {
+		return
+	}
+
+	switch {
+	case update.Message != nil:
+		b.handleMessage(update.Message)
+	case update.CallbackQuery != nil:
+		b.handleCallback(update.CallbackQuery)
+	}
+}
+
+func (b *Bot) startPolling() (tgbotapi.UpdatesChannel, error) {
+	// getUpdates is refused while a webhook is still registered.
+	if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return nil, err
+	}
+
+	updates, err := b.api.GetUpdates(tgbotapi.NewUpdate(0))
+	if err != nil {
+		return nil, err
+	}
+
 	lastUpdateID := 0
 	if len(updates) > 0 {
 		lastUpdateID = updates[len(updates)-1].UpdateID
@@ -67,14 +276,25 @@ func (b *Bot) Start() error {
 	u := tgbotapi.NewUpdate(lastUpdateID + 1)
 	u.Timeout = 60
 
-	updateChan := b.api.GetUpdatesChan(u)
+	return b.api.GetUpdatesChan(u), nil
+}
 
-	for update := range updateChan {
-		if update.Message != nil {
-			b.handleMessage(update.Message)
+func (b *Bot) Stop() error {
+	if b.fleetAgent != nil {
+		b.fleetAgent.StopUpdates()
+		select {
+		case <-b.agentStop:
+		default:
+			close(b.agentStop)
 		}
+		return nil
+	}
+
+	if b.webhook != nil {
+		return b.webhook.stop()
 	}
 
+	b.api.StopReceivingUpdates()
 	return nil
 }
 
@@ -82,73 +302,373 @@ func (b *Bot) handleMessage(message *tgbotapi.Message) {
 	userID := message.From.ID
 	chatID := message.Chat.ID
 	text := message.Text
+	group := !message.Chat.IsPrivate()
+
+	if group && !b.config.IsChatAllowed(chatID) {
+		return
+	}
+
+	isFile := message.Document != nil || message.Photo != nil || message.Video != nil
+	name, args, mentioned, isCommand := commands.ParseCommand(text, b.telegram.UserName())
+	name, args = b.expandAlias(name, args)
+	cmd, found := b.registry.Lookup(name)
 
-	// log.Printf("[%s] %s", message.From.UserName, text)
+	// In a group most messages are people talking to each other. Only react
+	// to commands that are clearly ours and to allowed uploads.
+	if group {
+		switch {
+		case isFile:
+			if !b.config.ChatAllows(chatID, commands.FileUploadName) {
+				return
+			}
+		case !isCommand, !found && !mentioned:
+			return
+		}
+	}
 
 	if !b.config.IsAuthorized(userID) {
-		msg := tgbotapi.NewMessage(chatID, "No access.")
-		b.api.Send(msg)
+		if b.guard.IsIgnored(userID) {
+			return
+		}
+		if b.handleUnauthorized(chatID, message.From, describeMessage(message)) {
+			b.replyNoAccess(chatID)
+		}
 		return
 	}
 
 	if message.Document != nil || message.Photo != nil || message.Video != nil {
-		b.fileHandler.HandleFileCommand(chatID, message)
+		attachment := attachmentFromMessage(message)
+		req := &commands.Request{
+			Context:  context.Background(),
+			ChatID:   chatID,
+			UserID:   userID,
+			UserName: displayName(message.From),
+			Command:  commands.FileUploadName,
+			Args:     attachment.FileName,
+		}
+
+		if !b.config.CanRun(userID, commands.FileUploadName, commands.FileUploadRole) {
+			b.record(req, audit.OutcomeDenied, nil, time.Now())
+			b.replyNoAccess(chatID)
+			return
+		}
+
+		upload := func() {
+			b.submit(chatID, false, func() {
+				started := time.Now()
+				b.fileHandler.HandleFileCommand(chatID, attachment)
+				b.record(req, audit.OutcomeOK, nil, started)
+			})
+		}
+		if !b.holdForCode(chatID, userID, commands.FileUploadName, upload) {
+			upload()
+		}
+		return
+	}
+
+	if !isCommand || !found {
+		b.handleUnknownCommand(chatID)
+		return
+	}
+
+	req := &commands.Request{
+		Context:  context.Background(),
+		ChatID:   chatID,
+		UserID:   userID,
+		UserName: displayName(message.From),
+		Command:  name,
+		Args:     args,
+		Text:     text,
+	}
+
+	if !b.canRun(userID, cmd) {
+		b.record(req, audit.OutcomeDenied, nil, time.Now())
+		b.replyNoAccess(chatID)
+		return
+	}
+
+	if group && !b.config.ChatAllows(chatID, cmd.Info().Name) {
+		b.record(req, audit.OutcomeDenied, nil, time.Now())
+		msg := transport.NewMessage(chatID, fmt.Sprintf("/%s is only available in a private chat with the bot.", cmd.Info().Name))
+		b.replies.Send(msg)
+		return
+	}
+
+	b.dispatchChecked(cmd, req, cmd.Handle)
+}
+
+func (b *Bot) handleCallback(query *tgbotapi.CallbackQuery) {
+	userID := query.From.ID
+
+	var chatID int64
+	if query.Message != nil {
+		chatID = query.Message.Chat.ID
+		if !query.Message.Chat.IsPrivate() && !b.config.IsChatAllowed(chatID) {
+			return
+		}
+	}
+
+	if !b.config.IsAuthorized(userID) {
+		if b.guard.IsIgnored(userID) {
+			return
+		}
+		if b.handleUnauthorized(chatID, query.From, "button: "+query.Data) {
+			b.messenger.AnswerCallback(query.ID, "No access.")
+		}
+		return
+	}
+
+	name, payload, ok := commands.ParseCallbackData(query.Data)
+	cmd, found := b.registry.Lookup(name)
+	handler, interactive := cmd.(commands.CallbackHandler)
+	if query.Message == nil || !ok || !found || !interactive {
+		b.messenger.AnswerCallback(query.ID, "This button is no longer available.")
+		return
+	}
+
+	req := &commands.Request{
+		Context:    context.Background(),
+		ChatID:     query.Message.Chat.ID,
+		UserID:     userID,
+		UserName:   displayName(query.From),
+		Command:    name,
+		Args:       payload,
+		Text:       query.Data,
+		CallbackID: query.ID,
+		MessageID:  query.Message.MessageID,
+	}
+
+	if !b.canRun(userID, cmd) {
+		b.record(req, audit.OutcomeDenied, nil, time.Now())
+		b.messenger.AnswerCallback(query.ID, "No access.")
 		return
 	}
 
+	// Hidden commands such as confirm only answer prompts that an allowed
+	// command already posted.
+	if !query.Message.Chat.IsPrivate() && !cmd.Info().Hidden && !b.config.ChatAllows(req.ChatID, name) {
+		b.record(req, audit.OutcomeDenied, nil, time.Now())
+		b.messenger.AnswerCallback(query.ID, "Only available in a private chat.")
+		return
+	}
+
+	b.messenger.AnswerCallback(query.ID, "")
+
+	b.dispatchChecked(cmd, req, handler.HandleCallback)
+}
+
+// dispatchChecked dispatches right away unless the command first needs a
+// TOTP code from the user.
+func (b *Bot) dispatchChecked(cmd commands.Command, req *commands.Request, run func(req *commands.Request) error) {
+	resume := func() {
+		b.dispatch(cmd, req, run)
+	}
+	if !b.holdForCode(req.ChatID, req.UserID, cmd.Info().Name, resume) {
+		resume()
+	}
+}
+
+// dispatch runs the command inline or hands it to the worker pool. It only
+// returns an error when the pool turned the command away.
+func (b *Bot) dispatch(cmd commands.Command, req *commands.Request, run func(req *commands.Request) error) error {
+	switch cmd.Info().Exec {
+	case commands.ExecInline:
+		b.runCommand(req, run)
+		return nil
+	case commands.ExecHeavy:
+		job := b.jobs.Create(req.Command, req.UserID, req.UserName, req.ChatID)
+		req.Context = job.Context()
+		return b.submit(req.ChatID, true, func() {
+			if !b.jobs.Begin(job) {
+				b.record(req, audit.OutcomeCancelled, nil, time.Now())
+				return
+			}
+			b.jobs.Finish(job, b.runCommand(req, run))
+		})
+	default:
+		return b.submit(req.ChatID, false, func() {
+			b.runCommand(req, run)
+		})
+	}
+}
+
+var (
+	errBusy    = errors.New("bot is busy, please try again later")
+	errTimeout = errors.New("command did not finish in time")
+)
+
+// dispatchAndWait dispatches like dispatch and blocks until run returns.
+// err is errBusy, errTimeout or the request context's error when the
+// command never finished; cmdErr is what the command itself returned.
+func (b *Bot) dispatchAndWait(cmd commands.Command, req *commands.Request, run func(req *commands.Request) error, timeout time.Duration) (cmdErr error, err error) {
+	done := make(chan error, 1)
+	tracked := func(req *commands.Request) error {
+		err := run(req)
+		done <- err
+		return err
+	}
+
+	if err := b.dispatch(cmd, req, tracked); err != nil {
+		return nil, errBusy
+	}
+
+	// Heavy commands swap in their job's context, so this also notices a
+	// job cancelled before it started.
+	select {
+	case cmdErr = <-done:
+		return cmdErr, nil
+	case <-req.Context.Done():
+		return nil, req.Context.Err()
+	case <-time.After(timeout):
+		return nil, errTimeout
+	}
+}
+
+func displayName(user *tgbotapi.User) string {
+	name := user.FirstName
+	if user.LastName != "" {
+		name += " " + user.LastName
+	}
+	return name
+}
+
+func (b *Bot) submit(chatID int64, heavy bool, fn func()) error {
+	err := b.pool.Submit(chatID, heavy, fn)
+	if err != nil {
+		msg := transport.NewMessage(chatID, "Bot is busy, please try again later.")
+		b.replies.Send(msg)
+	}
+	return err
+}
+
+func (b *Bot) runCommand(req *commands.Request, run func(req *commands.Request) error) error {
+	started := time.Now()
+	err := run(req)
+	b.record(req, outcomeOf(req, err), err, started)
+	// Handlers have already explained the failures they return as Failed.
+	var failed *commands.Failed
+	if err != nil && !errors.As(err, &failed) {
+		msg := transport.NewMessage(req.ChatID, fmt.Sprintf("Command failed: %v", err))
+		b.replies.Send(msg)
+	}
+	return err
+}
+
+func (b *Bot) canRun(userID int64, cmd commands.Command) bool {
+	info := cmd.Info()
+	return b.config.CanRun(userID, info.Name, info.Role)
+}
+
+func (b *Bot) canRunByName(userID int64, name string) bool {
+	cmd, ok := b.registry.Lookup(name)
+	return ok && b.canRun(userID, cmd)
+}
+
+// syncCommandMenu gives every authorized user a menu with just the commands
+// their role allows, and every allowed group the commands usable there.
+// Everyone else sees no menu at all.
+func (b *Bot) syncCommandMenu() error {
+	if _, err := b.api.Request(tgbotapi.NewDeleteMyCommands()); err != nil {
+		return err
+	}
+
+	for _, userID := range b.config.AuthorizedIDs() {
+		var botCommands []tgbotapi.BotCommand
+		for _, cmd := range b.registry.Commands() {
+			info := cmd.Info()
+			if info.Hidden || !b.canRun(userID, cmd) {
+				continue
+			}
+			botCommands = append(botCommands, tgbotapi.BotCommand{
+				Command:     info.Name,
+				Description: info.Description,
+			})
+		}
+		botCommands = append(botCommands, b.aliasMenu(func(cmd commands.Command) bool {
+			return b.canRun(userID, cmd)
+		})...)
+
+		scope := tgbotapi.NewBotCommandScopeChat(userID)
+		if _, err := b.api.Request(tgbotapi.NewSetMyCommandsWithScope(scope, botCommands...)); err != nil {
+			slog.Warn("failed to set command menu", "user_id", userID, "error", err)
+		}
+	}
+
+	// Group menus are shared by every member, so they follow the chat's
+	// command list rather than anyone's role.
+	for _, chatID := range b.config.AllowedChatIDs() {
+		var botCommands []tgbotapi.BotCommand
+		for _, cmd := range b.registry.Commands() {
+			info := cmd.Info()
+			if info.Hidden || !b.config.ChatAllows(chatID, info.Name) {
+				continue
+			}
+			botCommands = append(botCommands, tgbotapi.BotCommand{
+				Command:     info.Name,
+				Description: info.Description,
+			})
+		}
+		botCommands = append(botCommands, b.aliasMenu(func(cmd commands.Command) bool {
+			return b.config.ChatAllows(chatID, cmd.Info().Name)
+		})...)
+
+		scope := tgbotapi.NewBotCommandScopeChat(chatID)
+		if _, err := b.api.Request(tgbotapi.NewSetMyCommandsWithScope(scope, botCommands...)); err != nil {
+			slog.Warn("failed to set command menu", "chat_id", chatID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func describeMessage(message *tgbotapi.Message) string {
 	switch {
-	case text == "/start":
-		b.handleStartCommand(chatID)
-	case text == "/help":
-		b.helpHandler.HandleHelpCommand(chatID)
-	case text == "/info":
-		b.infoHandler.HandleInfoCommand(chatID)
-	case text == "/processes":
-		b.processHandler.HandleProcessCommand(chatID)
-	case text == "/ss":
-		b.screenshotHandler.HandleScreenshotCommand(chatID)
-	case text == "/ssa":
-		b.screenshotHandler.HandleScreenshotAllCommand(chatID)
-	case text == "/ssm":
-		b.screenshotHandler.HandleMainMonitorCommand(chatID)
-	case text == "/vid":
-		b.videoHandler.HandleVideoCommand(chatID)
-	case text == "/audio":
-		b.audioHandler.HandleAudioCommand(chatID)
-	case text == "/displays":
-		displayInfo := b.screenshotHandler.GetDisplayInfo()
-		msg := tgbotapi.NewMessage(chatID, displayInfo)
-		msg.ParseMode = "Markdown"
-		b.api.Send(msg)
-	case text == "/files":
-		fileInfo := b.fileHandler.GetSupportedFileTypes()
-		msg := tgbotapi.NewMessage(chatID, fileInfo)
-		msg.ParseMode = "Markdown"
-		b.api.Send(msg)
-	case strings.HasPrefix(text, "/kill "):
-		b.processHandler.HandleKillProcessCommand(chatID, text)
-	case strings.HasPrefix(text, "/browser "):
-		b.browserKiller.HandleBrowserKillerCommand(chatID, text)
-	case strings.HasPrefix(text, "/msg "):
-		userName := message.From.FirstName
-		if message.From.LastName != "" {
-			userName += " " + message.From.LastName
-		}
-		b.msgHandler.HandleMsgCommand(chatID, text, userName)
+	case message.Text != "":
+		return message.Text
+	case message.Document != nil:
+		return "document: " + message.Document.FileName
+	case message.Photo != nil:
+		return "photo"
+	case message.Video != nil:
+		return "video"
 	default:
-		b.handleUnknownCommand(chatID)
+		return "(non-text message)"
+	}
+}
+
+func attachmentFromMessage(message *tgbotapi.Message) transport.Attachment {
+	switch {
+	case message.Document != nil:
+		return transport.Attachment{
+			FileID:   message.Document.FileID,
+			FileName: message.Document.FileName,
+			MimeType: message.Document.MimeType,
+		}
+	case len(message.Photo) > 0:
+		photo := message.Photo[len(message.Photo)-1]
+		return transport.Attachment{
+			FileID:   photo.FileID,
+			FileName: "photo.jpg",
+		}
+	case message.Video != nil:
+		return transport.Attachment{
+			FileID:   message.Video.FileID,
+			FileName: "video.mp4",
+			MimeType: message.Video.MimeType,
+		}
+	default:
+		return transport.Attachment{}
 	}
 }
 
 func (b *Bot) handleStartCommand(chatID int64) {
-	msg := tgbotapi.NewMessage(chatID, b.helpHandler.GetStartMessage())
-	msg.ParseMode = "Markdown"
-	b.api.Send(msg)
+	msg := transport.NewMarkdownMessage(chatID, b.helpHandler.GetStartMessage())
+	b.replies.Send(msg)
 }
 
 func (b *Bot) handleUnknownCommand(chatID int64) {
-	msg := tgbotapi.NewMessage(chatID, b.helpHandler.GetUnknownCommandMessage())
-	b.api.Send(msg)
+	msg := transport.NewMessage(chatID, b.helpHandler.GetUnknownCommandMessage())
+	b.replies.Send(msg)
 }
 
 func (b *Bot) SendMessage(chatID int64, text string) {
@@ -163,7 +683,7 @@ func (b *Bot) SetConsoleHandler(handler interface {
 	SendPopup(message string)
 }) {
 	b.consoleHandler = handler
-	b.msgHandler = commands.NewMsgHandler(b.api, handler)
+	b.msgHandler = commands.NewMsgHandler(b.replies, handler)
 }
 
 func (b *Bot) GetProcessList() ([]commands.ProcessInfo, error) {
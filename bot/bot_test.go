@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"path/filepath"
+	"remoteadmin/audit"
+	"remoteadmin/commands"
+	"remoteadmin/config"
+	"remoteadmin/jobs"
+	"remoteadmin/queue"
+	"remoteadmin/totp"
+	"remoteadmin/transport"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	testUserID = 1001
+	testToken  = "test-token-0123456789abcdef"
+)
+
+type popups struct {
+	shown []string
+}
+
+func (p *popups) SendPopup(message string) {
+	p.shown = append(p.shown, message)
+}
+
+// newTestBot builds a bot with the built-in commands that replies into a
+// recorder instead of Telegram. testUserID is an admin, reachable over the
+// API with testToken.
+func newTestBot(t *testing.T, cfg *config.Config) (*Bot, *transport.Recorder) {
+	t.Helper()
+
+	cfg.Users = append(cfg.Users, config.User{ID: testUserID, Name: "tester", Role: config.RoleAdmin})
+	cfg.API.Tokens = append(cfg.API.Tokens, config.APIToken{Name: "tester", Token: testToken, UserID: testUserID})
+	cfg.API.TimeoutSeconds = 5
+	cfg.ConfirmTimeoutSeconds = 60
+
+	auditLog, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"), 1<<20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := &tgbotapi.BotAPI{Self: tgbotapi.User{UserName: "AdminBot"}}
+	recorder := transport.NewRecorder()
+	messenger := transport.NewRateLimited(recorder, transport.Limits{})
+	replies := transport.NewRouter(messenger)
+	jobManager := jobs.NewManager()
+	confirmer := commands.NewConfirmer(replies, cfg.ConfirmTimeout())
+	registry := commands.NewRegistry()
+
+	b := &Bot{
+		api:            api,
+		telegram:       transport.NewTelegram(api),
+		messenger:      messenger,
+		replies:        replies,
+		config:         cfg,
+		registry:       registry,
+		pool:           queue.NewPool(2, 1),
+		jobs:           jobManager,
+		processHandler: commands.NewProcessHandler(replies, confirmer),
+		helpHandler:    commands.NewHelpHandler(replies, registry, cfg),
+		jobsHandler:    commands.NewJobsHandler(replies, jobManager),
+		confirmer:      confirmer,
+		authenticator:  totp.NewAuthenticator(cfg),
+		held:           heldCommands{commands: make(map[int64]heldCommand)},
+		audit:          auditLog,
+	}
+	b.twoFactorHandler = commands.NewTwoFactorHandler(replies, b.authenticator, "remoteadmin", b.resumeHeld)
+	b.registerCommands()
+	if err := b.registerMacros(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		b.pool.Close()
+		messenger.Close()
+		auditLog.Close()
+	})
+	return b, recorder
+}
@@ -0,0 +1,230 @@
+package bot
+
+import (
+	"remoteadmin/commands"
+	"remoteadmin/config"
+	"remoteadmin/transport"
+)
+
+func (b *Bot) registerCommands() {
+	b.registry.MustRegister(
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "info",
+			Description: "System hardware and software information",
+			Category:    "System Information",
+			Role:        config.RoleViewer,
+		}, func(req *commands.Request) error {
+			b.infoHandler.HandleInfoCommand(req.ChatID)
+			return nil
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "displays",
+			Description: "Display information and resolutions",
+			Category:    "System Information",
+			Role:        config.RoleViewer,
+		}, func(req *commands.Request) error {
+			b.replies.Send(b.screenshotHandler.GetDisplayInfo().Message(req.ChatID))
+			return nil
+		}),
+		commands.NewInteractiveCommand(commands.CommandInfo{
+			Name:        "ss",
+			Description: "Pick a monitor to screenshot, or capture each one",
+			Category:    "Screenshots & Recording",
+			Role:        config.RoleOperator,
+			Exec:        commands.ExecHeavy,
+		}, func(req *commands.Request) error {
+			return b.screenshotHandler.HandleScreenshotCommand(req.ChatID)
+		}, func(req *commands.Request) error {
+			return b.screenshotHandler.HandleScreenshotCallback(req.ChatID, req.Args)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "ssa",
+			Description: "Screenshot all monitors as one image",
+			Category:    "Screenshots & Recording",
+			Role:        config.RoleOperator,
+			Exec:        commands.ExecHeavy,
+		}, func(req *commands.Request) error {
+			return b.screenshotHandler.HandleScreenshotAllCommand(req.ChatID)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "ssm",
+			Description: "Screenshot main monitor only",
+			Category:    "Screenshots & Recording",
+			Role:        config.RoleOperator,
+			Exec:        commands.ExecHeavy,
+		}, func(req *commands.Request) error {
+			return b.screenshotHandler.HandleMainMonitorCommand(req.ChatID)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "vid",
+			Usage:       "/vid [--duration 5s] [--monitor N]",
+			Description: "Record a video of a monitor, 5 seconds by default (max 50MB)",
+			Category:    "Screenshots & Recording",
+			Role:        config.RoleAdmin,
+			Exec:        commands.ExecHeavy,
+		}, func(req *commands.Request) error {
+			return b.videoHandler.HandleVideoCommand(req.Context, req.ChatID, req.Args)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "audio",
+			Usage:       "/audio [--duration 10s]",
+			Description: "Record audio from the microphone, 10 seconds by default (max 50MB)",
+			Category:    "Screenshots & Recording",
+			Role:        config.RoleAdmin,
+			Exec:        commands.ExecHeavy,
+		}, func(req *commands.Request) error {
+			return b.audioHandler.HandleAudioCommand(req.Context, req.ChatID, req.Args)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "processes",
+			Usage:       "/processes [--sort cpu|memory|name|pid] [--limit 15]",
+			Description: "List running applications",
+			Category:    "Process Management",
+			Role:        config.RoleViewer,
+		}, func(req *commands.Request) error {
+			return b.processHandler.HandleProcessCommand(req.ChatID, req.Args, b.canRunByName(req.UserID, "kill"))
+		}),
+		commands.NewInteractiveCommand(commands.CommandInfo{
+			Name:        "kill",
+			Usage:       "/kill <PID>",
+			Description: "Kill a process by PID (asks for confirmation)",
+			Category:    "Process Management",
+			Role:        config.RoleOperator,
+		}, func(req *commands.Request) error {
+			return b.processHandler.HandleKillProcessCommand(req.ChatID, req.UserID, req.Args)
+		}, func(req *commands.Request) error {
+			return b.processHandler.HandleKillCallback(req.ChatID, req.UserID, req.Args)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "jobs",
+			Description: "List queued, running and recent jobs",
+			Category:    "Process Management",
+			Role:        config.RoleViewer,
+			Exec:        commands.ExecInline,
+		}, func(req *commands.Request) error {
+			b.jobsHandler.HandleJobsCommand(req.ChatID)
+			return nil
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "cancel",
+			Usage:       "/cancel <job id>",
+			Description: "Cancel a queued or running job",
+			Category:    "Process Management",
+			Role:        config.RoleOperator,
+			Exec:        commands.ExecInline,
+		}, func(req *commands.Request) error {
+			return b.jobsHandler.HandleCancelCommand(req.ChatID, req.Args)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "schedule",
+			Usage:       "/schedule add \"<cron>\" /command | list | remove <id>",
+			Description: "Run commands on a cron schedule",
+			Category:    "Process Management",
+			Role:        config.RoleOperator,
+			Exec:        commands.ExecInline,
+		}, func(req *commands.Request) error {
+			return b.scheduleHandler.HandleScheduleCommand(req.ChatID, req.UserID, req.UserName, req.Args)
+		}),
+		commands.NewInteractiveCommand(commands.CommandInfo{
+			Name:        "browser",
+			Usage:       "/browser start|stop|status|list",
+			Description: "Control browser monitoring",
+			Category:    "Browser Killer",
+			Role:        config.RoleOperator,
+		}, func(req *commands.Request) error {
+			return b.browserKiller.HandleBrowserKillerCommand(req.ChatID, req.Args)
+		}, func(req *commands.Request) error {
+			b.browserKiller.HandleBrowserKillerCallback(req.ChatID, req.MessageID, req.Args)
+			return nil
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "blocked",
+			Description: "List users ignored after unauthorized attempts",
+			Category:    "Security",
+			Role:        config.RoleAdmin,
+		}, func(req *commands.Request) error {
+			b.securityHandler.HandleBlockedCommand(req.ChatID)
+			return nil
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "unblock",
+			Usage:       "/unblock <user ID>",
+			Description: "Stop ignoring a user",
+			Category:    "Security",
+			Role:        config.RoleAdmin,
+		}, func(req *commands.Request) error {
+			return b.securityHandler.HandleUnblockCommand(req.ChatID, req.Args)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "audit",
+			Usage:       "/audit [user] [since]",
+			Description: "Show recent commands from the audit log",
+			Category:    "Security",
+			Role:        config.RoleAdmin,
+		}, func(req *commands.Request) error {
+			return b.auditHandler.HandleAuditCommand(req.ChatID, req.Args)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "totp",
+			Usage:       "/totp [enroll|confirm|disable|<code>]",
+			Description: "Two-factor codes for sensitive commands",
+			Category:    "Security",
+			Role:        config.RoleViewer,
+			Exec:        commands.ExecInline,
+			SecretArgs:  true,
+		}, func(req *commands.Request) error {
+			return b.twoFactorHandler.HandleTOTPCommand(req.ChatID, req.UserID, req.UserName, req.Args)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "msg",
+			Usage:       "/msg \"message\"",
+			Description: "Send message to console",
+			Category:    "Communication",
+			Role:        config.RoleOperator,
+			RawArgs:     true,
+		}, func(req *commands.Request) error {
+			return b.msgHandler.HandleMsgCommand(req.ChatID, req.Args, req.UserName)
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "help",
+			Description: "Show this help menu",
+			Category:    "Communication",
+			Role:        config.RoleViewer,
+		}, func(req *commands.Request) error {
+			b.helpHandler.HandleHelpCommand(req.ChatID, req.UserID)
+			return nil
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "start",
+			Description: "Show the welcome message",
+			Category:    "Communication",
+			Role:        config.RoleViewer,
+		}, func(req *commands.Request) error {
+			b.handleStartCommand(req.ChatID)
+			return nil
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "files",
+			Description: "Show supported file types",
+			Category:    "File Management",
+			Role:        config.RoleViewer,
+		}, func(req *commands.Request) error {
+			msg := transport.NewMarkdownMessage(req.ChatID, b.fileHandler.GetSupportedFileTypes())
+			b.replies.Send(msg)
+			return nil
+		}),
+		commands.NewInteractiveCommand(commands.CommandInfo{
+			Name:        "confirm",
+			Description: "Answer a pending confirmation",
+			Role:        config.RoleViewer,
+			Hidden:      true,
+		}, func(req *commands.Request) error {
+			msg := transport.NewMessage(req.ChatID, "Use the Confirm or Cancel buttons to answer a confirmation.")
+			b.replies.Send(msg)
+			return nil
+		}, func(req *commands.Request) error {
+			b.confirmer.HandleConfirmCallback(req.ChatID, req.UserID, req.Args)
+			return nil
+		}),
+	)
+}
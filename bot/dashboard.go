@@ -0,0 +1,350 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"remoteadmin/audit"
+	"remoteadmin/commands"
+	"remoteadmin/config"
+	"remoteadmin/dashboard"
+	"remoteadmin/guard"
+	"remoteadmin/hardware"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dashboardVia = "dashboard"
+
+	csrfHeader = "X-CSRF-Token"
+
+	// After maxLoginFailures wrong passwords within loginLockout from one
+	// address, or for one name, logins from there or for it are refused
+	// for loginLockout without checking the password.
+	maxLoginFailures = 5
+	loginLockout     = 15 * time.Minute
+
+	defaultAuditRows = 100
+	maxAuditRows     = 1000
+)
+
+type dashboardHandler func(w http.ResponseWriter, r *http.Request, session dashboard.Session)
+
+// startDashboard serves the web dashboard. Reads come straight from the
+// handlers' data; actions run as commands through apiRun, so roles,
+// two-factor codes and the audit log apply as they do in Telegram.
+func (b *Bot) startDashboard() error {
+	cfg := b.config.Dashboard
+	if !cfg.Enabled {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return err
+	}
+
+	b.sessions = dashboard.NewSessions(b.config.DashboardSessionTTL())
+	b.loginFailures = guard.NewLimiter(maxLoginFailures, loginLockout, loginLockout)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /login", b.dashboardLoginPage)
+	mux.HandleFunc("POST /login", b.dashboardLogin)
+	mux.HandleFunc("POST /logout", b.dashboardLogout)
+	mux.HandleFunc("GET /{$}", b.dashboardSession(b.dashboardIndex))
+	mux.HandleFunc("GET /data/stats", b.dashboardSession(b.dashboardStats))
+	mux.HandleFunc("GET /data/processes", b.dashboardSession(b.dashboardProcesses))
+	mux.HandleFunc("GET /data/browser", b.dashboardSession(b.dashboardBrowser))
+	mux.HandleFunc("GET /data/audit", b.dashboardSession(b.dashboardAudit))
+	mux.HandleFunc("POST /actions/kill/{pid}", b.dashboardAction(b.apiKill))
+	mux.HandleFunc("POST /actions/browser/{action}", b.dashboardAction(b.apiBrowser))
+
+	b.dashboardServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		var err error
+		if cfg.CertFile != "" {
+			err = b.dashboardServer.ServeTLS(listener, cfg.CertFile, cfg.KeyFile)
+		} else {
+			err = b.dashboardServer.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("dashboard listener stopped", "error", err)
+		}
+	}()
+
+	if cfg.CertFile == "" && !config.IsLoopbackAddr(cfg.ListenAddr) {
+		slog.Warn("dashboard is reachable from the network without TLS; passwords are sent in the clear", "addr", cfg.ListenAddr)
+	}
+	slog.Info("dashboard listening", "addr", cfg.ListenAddr, "tls", cfg.CertFile != "")
+	return nil
+}
+
+func (b *Bot) stopDashboard() {
+	if b.dashboardServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := b.dashboardServer.Shutdown(ctx); err != nil {
+		slog.Warn("failed to stop dashboard listener", "error", err)
+	}
+}
+
+func (b *Bot) dashboardLoginPage(w http.ResponseWriter, r *http.Request) {
+	hostname, _ := os.Hostname()
+	dashboard.RenderLogin(w, dashboard.LoginPage{Host: hostname})
+}
+
+func (b *Bot) dashboardLogin(w http.ResponseWriter, r *http.Request) {
+	name := r.PostFormValue("name")
+	password := r.PostFormValue("password")
+
+	keys := loginKeys(r.RemoteAddr, name)
+	for _, key := range keys {
+		if wait, ok := b.loginFailures.Allow(key); !ok {
+			slog.Warn("dashboard login refused after too many failures", "name", name, "remote", r.RemoteAddr)
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			b.renderLoginError(w, http.StatusTooManyRequests, "Too many failed logins. Try again later.")
+			return
+		}
+	}
+
+	for _, login := range b.config.Dashboard.Logins {
+		nameOK := subtle.ConstantTimeCompare([]byte(name), []byte(login.Name)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(login.Password)) == 1
+		if !nameOK || !passwordOK {
+			continue
+		}
+
+		for _, key := range keys {
+			b.loginFailures.Reset(key)
+		}
+		session := b.sessions.Create(login.UserID, login.Name)
+		http.SetCookie(w, &http.Cookie{
+			Name:     dashboard.CookieName,
+			Value:    session.ID,
+			Path:     "/",
+			Expires:  session.Expires,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+		slog.Info("dashboard login", "name", login.Name, "user_id", login.UserID, "remote", r.RemoteAddr)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	slog.Warn("failed dashboard login", "name", name, "remote", r.RemoteAddr)
+	b.record(&commands.Request{
+		UserName: name,
+		Command:  "unauthorized",
+		Args:     "login from " + r.RemoteAddr,
+		Via:      dashboardVia,
+	}, audit.OutcomeRejected, nil, time.Now())
+
+	for _, key := range keys {
+		b.loginFailures.Fail(key)
+	}
+	b.renderLoginError(w, http.StatusUnauthorized, "Wrong name or password.")
+}
+
+// loginKeys are what failed logins are counted against: the address
+// they come from and the name they try.
+func loginKeys(remoteAddr, name string) []string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return []string{"addr:" + host, "name:" + strings.ToLower(name)}
+}
+
+func (b *Bot) renderLoginError(w http.ResponseWriter, status int, message string) {
+	hostname, _ := os.Hostname()
+	w.WriteHeader(status)
+	dashboard.RenderLogin(w, dashboard.LoginPage{Host: hostname, Error: message})
+}
+
+func (b *Bot) dashboardLogout(w http.ResponseWriter, r *http.Request) {
+	if session, ok := b.currentSession(r); ok && validCSRF(r.PostFormValue("csrf"), session) {
+		b.sessions.Delete(session.ID)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     dashboard.CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func (b *Bot) currentSession(r *http.Request) (dashboard.Session, bool) {
+	cookie, err := r.Cookie(dashboard.CookieName)
+	if err != nil {
+		return dashboard.Session{}, false
+	}
+	session, ok := b.sessions.Get(cookie.Value)
+	if !ok || !b.config.IsAuthorized(session.UserID) {
+		return dashboard.Session{}, false
+	}
+	return session, true
+}
+
+// dashboardSession sends signed-out browsers to the login page; data
+// requests get a 401 instead so the page script can do the same.
+func (b *Bot) dashboardSession(next dashboardHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := b.currentSession(r)
+		if !ok {
+			if r.URL.Path == "/" {
+				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				return
+			}
+			writeAPIError(w, &apiError{Status: http.StatusUnauthorized, Message: "not signed in"})
+			return
+		}
+		next(w, r, session)
+	}
+}
+
+// dashboardAction runs an API handler as the signed-in user. Actions need
+// the session's CSRF token as well as the cookie.
+func (b *Bot) dashboardAction(next apiHandler) http.HandlerFunc {
+	return b.dashboardSession(func(w http.ResponseWriter, r *http.Request, session dashboard.Session) {
+		if !validCSRF(r.Header.Get(csrfHeader), session) {
+			writeAPIError(w, &apiError{Status: http.StatusForbidden, Message: "missing or invalid CSRF token"})
+			return
+		}
+
+		call, detach := b.newCall(session.UserID, session.Name, dashboardVia)
+		defer detach()
+
+		next(w, r, call)
+	})
+}
+
+func validCSRF(token string, session dashboard.Session) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRF)) == 1
+}
+
+func (b *Bot) dashboardPanels(userID int64) dashboard.Panels {
+	return dashboard.Panels{
+		Stats:     b.canRunByName(userID, "info"),
+		Processes: b.canRunByName(userID, "processes"),
+		Kill:      b.canRunByName(userID, "kill"),
+		Browser:   b.canRunByName(userID, "browser"),
+		Audit:     b.canRunByName(userID, "audit"),
+	}
+}
+
+func (b *Bot) dashboardIndex(w http.ResponseWriter, r *http.Request, session dashboard.Session) {
+	hostname, _ := os.Hostname()
+	w.Header().Set("Cache-Control", "no-store")
+	dashboard.RenderIndex(w, dashboard.IndexPage{
+		Host:   hostname,
+		Name:   session.Name,
+		CSRF:   session.CSRF,
+		Panels: b.dashboardPanels(session.UserID),
+	})
+}
+
+// requirePanel answers 403 when the user's role doesn't cover a panel.
+func requirePanel(w http.ResponseWriter, allowed bool) bool {
+	if !allowed {
+		writeAPIError(w, &apiError{Status: http.StatusForbidden, Message: "no access"})
+	}
+	return allowed
+}
+
+func (b *Bot) dashboardStats(w http.ResponseWriter, r *http.Request, session dashboard.Session) {
+	if !requirePanel(w, b.dashboardPanels(session.UserID).Stats) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		UptimeSeconds float64
+		Usage         hardware.Usage
+	}{
+		UptimeSeconds: time.Since(b.startTime).Seconds(),
+		Usage:         hardware.GetUsage(),
+	})
+}
+
+func (b *Bot) dashboardProcesses(w http.ResponseWriter, r *http.Request, session dashboard.Session) {
+	if !requirePanel(w, b.dashboardPanels(session.UserID).Processes) {
+		return
+	}
+
+	processes, err := b.processHandler.GetProcessList()
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if processes == nil {
+		processes = []commands.ProcessInfo{}
+	}
+	writeJSON(w, http.StatusOK, processes)
+}
+
+func (b *Bot) dashboardBrowser(w http.ResponseWriter, r *http.Request, session dashboard.Session) {
+	if !requirePanel(w, b.dashboardPanels(session.UserID).Browser) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Monitoring  bool
+		BannedSites []string
+		Violations  []commands.Violation
+	}{
+		Monitoring:  b.browserKiller.IsMonitoring(),
+		BannedSites: b.browserKiller.BannedSites(),
+		Violations:  b.browserKiller.Violations(),
+	})
+}
+
+// dashboardAudit returns matching audit entries, newest first.
+func (b *Bot) dashboardAudit(w http.ResponseWriter, r *http.Request, session dashboard.Session) {
+	if !requirePanel(w, b.dashboardPanels(session.UserID).Audit) {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := audit.Filter{User: query.Get("user"), Limit: defaultAuditRows}
+	if since := query.Get("since"); since != "" {
+		parsed, err := audit.ParseFilter([]string{since})
+		if err != nil || parsed.Since.IsZero() {
+			writeAPIError(w, &apiError{Status: http.StatusBadRequest, Message: "since must be a duration like 2h or 7d, or a date"})
+			return
+		}
+		filter.Since = parsed.Since
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit > 0 {
+		filter.Limit = min(limit, maxAuditRows)
+	}
+
+	entries, err := b.audit.Query(filter)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	newest := make([]audit.Entry, len(entries))
+	for i, entry := range entries {
+		newest[len(entries)-1-i] = entry
+	}
+	writeJSON(w, http.StatusOK, newest)
+}
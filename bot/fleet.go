@@ -0,0 +1,202 @@
+package bot
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"remoteadmin/audit"
+	"remoteadmin/commands"
+	"remoteadmin/config"
+	"remoteadmin/fleet"
+	"remoteadmin/transport"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// registerFleetCommands adds /hosts and /use on a coordinator.
+func (b *Bot) registerFleetCommands() {
+	b.registry.MustRegister(
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "hosts",
+			Description: "List the hosts in the fleet",
+			Category:    "Fleet",
+			Role:        config.RoleAdmin,
+			Exec:        commands.ExecInline,
+		}, func(req *commands.Request) error {
+			b.fleetHandler.HandleHostsCommand(req.ChatID, req.UserID)
+			return nil
+		}),
+		commands.NewCommand(commands.CommandInfo{
+			Name:        "use",
+			Usage:       "/use [host]",
+			Description: "Send your commands to another host",
+			Category:    "Fleet",
+			Role:        config.RoleAdmin,
+			Exec:        commands.ExecInline,
+		}, func(req *commands.Request) error {
+			return b.fleetHandler.HandleUseCommand(req.ChatID, req.UserID, req.Args)
+		}),
+	)
+}
+
+// agentTLSConfig builds the agent's TLS settings. A CA file replaces the
+// system roots, for coordinators with their own certificate.
+func agentTLSConfig(cfg config.FleetConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	return tlsConfig, nil
+}
+
+// startFleet listens for agents on a coordinator.
+func (b *Bot) startFleet() error {
+	if b.fleet == nil {
+		return nil
+	}
+
+	cfg := b.config.Fleet
+	listener, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	listener = tls.NewListener(listener, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+
+	go func() {
+		if err := b.fleet.Serve(listener); err != nil {
+			slog.Error("fleet listener stopped", "error", err)
+		}
+	}()
+
+	slog.Info("fleet coordinator listening", "addr", cfg.ListenAddr, "host", cfg.Name)
+	return nil
+}
+
+// runAgent handles the updates the coordinator forwards until Stop.
+func (b *Bot) runAgent() error {
+	go b.fleetAgent.Run()
+	slog.Info("running as fleet agent", "coordinator", b.config.Fleet.CoordinatorAddr, "host", b.config.Fleet.Name)
+
+	for {
+		select {
+		case update := <-b.fleetAgent.Updates():
+			b.handleUpdate(update)
+		case <-b.agentStop:
+			return nil
+		}
+	}
+}
+
+// forwardUpdate sends an update to another host when it is meant for one:
+// a message starting with @host, a command from a user who picked a host
+// with /use, or a button on a message an agent sent. It reports whether
+// the update was taken care of; anything else is handled here, with an
+// @host prefix naming this host removed.
+func (b *Bot) forwardUpdate(update tgbotapi.Update) bool {
+	switch {
+	case update.Message != nil:
+		return b.forwardMessage(update)
+	case update.CallbackQuery != nil:
+		return b.forwardCallback(update)
+	}
+	return false
+}
+
+func (b *Bot) forwardMessage(update tgbotapi.Update) bool {
+	message := update.Message
+	userID := message.From.ID
+	chatID := message.Chat.ID
+
+	// Strangers and chats the bot ignores are dealt with here as usual.
+	if !b.config.IsAuthorized(userID) || !message.Chat.IsPrivate() && !b.config.IsChatAllowed(chatID) {
+		return false
+	}
+
+	host, rest, explicit := b.hostPrefix(message.Text)
+	if explicit {
+		if !b.canRunByName(userID, "use") {
+			req := &commands.Request{ChatID: chatID, UserID: userID, UserName: displayName(message.From), Command: "use", Args: host}
+			b.record(req, audit.OutcomeDenied, nil, time.Now())
+			b.replyNoAccess(chatID)
+			return true
+		}
+		message.Text = rest
+		if strings.EqualFold(host, b.config.Fleet.Name) {
+			return false
+		}
+	} else {
+		host = b.fleet.Selected(userID)
+		if host == "" {
+			return false
+		}
+		// /hosts and /use always answer here, so users can find their
+		// way back.
+		name, _, _, _ := commands.ParseCommand(message.Text, b.telegram.UserName())
+		if name == "hosts" || name == "use" {
+			return false
+		}
+	}
+
+	if err := b.fleet.Forward(host, update); err != nil {
+		text := fmt.Sprintf("%s is not connected. /hosts lists the hosts that are; /use %s comes back here.", host, b.config.Fleet.Name)
+		if !errors.Is(err, fleet.ErrUnknownHost) {
+			text = fmt.Sprintf("Failed to reach %s: %v", host, err)
+		}
+		msg := transport.NewMessage(chatID, text)
+		b.replies.Send(msg)
+	}
+	return true
+}
+
+// hostPrefix splits "@host /command" into the host and the command. A
+// mention of the bot itself is not a host.
+func (b *Bot) hostPrefix(text string) (host, rest string, ok bool) {
+	if !strings.HasPrefix(text, "@") {
+		return "", text, false
+	}
+	first, rest, _ := strings.Cut(text, " ")
+	host = strings.TrimPrefix(first, "@")
+	if host == "" || strings.EqualFold(host, b.telegram.UserName()) {
+		return "", text, false
+	}
+	return host, strings.TrimSpace(rest), true
+}
+
+func (b *Bot) forwardCallback(update tgbotapi.Update) bool {
+	query := update.CallbackQuery
+	if query.Message == nil || !b.config.IsAuthorized(query.From.ID) {
+		return false
+	}
+
+	host, ok := b.fleet.Owner(query.Message.Chat.ID, query.Message.MessageID)
+	if !ok {
+		return false
+	}
+
+	if err := b.fleet.Forward(host, update); err != nil {
+		b.messenger.AnswerCallback(query.ID, host+" is not connected.")
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"remoteadmin/config"
+	"remoteadmin/totp"
+	"remoteadmin/transport"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestAgentAsksForCodeByHostName(t *testing.T) {
+	secret, err := totp.NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, recorder := newTestBot(t, &config.Config{
+		Fleet: config.FleetConfig{Role: config.FleetAgent, Name: "web-1"},
+		TwoFactor: config.TwoFactorConfig{
+			SensitiveCommands: []string{"jobs"},
+			MaxAttempts:       3,
+			Secrets:           map[int64]string{testUserID: secret},
+		},
+	})
+
+	b.handleUpdate(testUpdate("/jobs"))
+	want := "/jobs needs a one-time code. Send @web-1 /totp <code> to continue."
+	if got := lastReply(t, recorder); got != want {
+		t.Fatalf("prompt = %q, want %q", got, want)
+	}
+
+	// The coordinator strips the host and forwards the rest to the agent.
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, rest, ok := b.hostPrefix("@web-1 /totp " + code)
+	if !ok || host != "web-1" {
+		t.Fatalf("hostPrefix = %q, %q, %v", host, rest, ok)
+	}
+
+	b.handleUpdate(testUpdate(rest))
+	if got := lastReply(t, recorder); got != "No jobs" {
+		t.Errorf("held command did not resume, last reply %q", got)
+	}
+}
+
+func testUpdate(text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		From: &tgbotapi.User{ID: testUserID, FirstName: "tester"},
+		Chat: &tgbotapi.Chat{ID: testUserID, Type: "private"},
+		Text: text,
+	}}
+}
+
+func lastReply(t *testing.T, recorder *transport.Recorder) string {
+	t.Helper()
+	messages := recorder.Messages()
+	if len(messages) == 0 {
+		t.Fatal("no replies")
+	}
+	return messages[len(messages)-1].Text
+}
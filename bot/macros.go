@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"remoteadmin/audit"
+	"remoteadmin/commands"
+	"remoteadmin/config"
+	"remoteadmin/transport"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// macroStepTimeout bounds how long a macro waits for one step.
+const macroStepTimeout = 10 * time.Minute
+
+// registerMacros registers each macro as a command of its own, then checks
+// the configured aliases against the registry, so an alias may point at a
+// macro.
+func (b *Bot) registerMacros() error {
+	names := make([]string, 0, len(b.config.Macros))
+	for name := range b.config.Macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		macro := b.config.Macros[name]
+		for _, step := range macro.Steps {
+			stepName, args, _, _ := commands.ParseCommand(step, b.telegram.UserName())
+			stepName, _ = b.expandAlias(stepName, args)
+			if _, ok := b.registry.Lookup(stepName); !ok {
+				return fmt.Errorf("macro %q: step %q is not a command", name, step)
+			}
+		}
+
+		description := macro.Description
+		if description == "" {
+			description = fmt.Sprintf("Run %d steps", len(macro.Steps))
+		}
+
+		err := b.registry.Register(commands.NewCommand(commands.CommandInfo{
+			Name:        name,
+			Usage:       commands.MacroUsage(name, macro),
+			Description: description,
+			Category:    "Macros",
+			// Every step checks its own role, so anyone may start a macro.
+			Role: config.RoleViewer,
+			// The steps go through the queue themselves; waiting for them
+			// from a worker would block the chat's own queue.
+			Exec: commands.ExecInline,
+		}, func(req *commands.Request) error {
+			go b.runMacro(req, name, macro)
+			return nil
+		}))
+		if err != nil {
+			return fmt.Errorf("macro %q: %w", name, err)
+		}
+	}
+
+	for name, target := range b.config.Aliases {
+		if _, exists := b.registry.Lookup(name); exists {
+			return fmt.Errorf("alias %q clashes with the /%s command", name, name)
+		}
+		if _, ok := b.aliasTarget(name); !ok {
+			return fmt.Errorf("alias %q points at %q, which is not a command", name, target)
+		}
+	}
+
+	return nil
+}
+
+// expandAlias turns an alias into the command line it stands for, keeping
+// any arguments the user added. Other names come back unchanged.
+func (b *Bot) expandAlias(name, args string) (string, string) {
+	target, ok := b.config.Aliases[name]
+	if !ok {
+		return name, args
+	}
+
+	targetName, targetArgs, _, _ := commands.ParseCommand(target, b.telegram.UserName())
+	if args != "" {
+		targetArgs = strings.TrimSpace(targetArgs + " " + args)
+	}
+	return targetName, targetArgs
+}
+
+// aliasTarget returns the command an alias runs.
+func (b *Bot) aliasTarget(name string) (commands.Command, bool) {
+	if _, ok := b.config.Aliases[name]; !ok {
+		return nil, false
+	}
+	targetName, _ := b.expandAlias(name, "")
+	return b.registry.Lookup(targetName)
+}
+
+// aliasMenu lists the aliases whose target passes allowed, for the command
+// menu.
+func (b *Bot) aliasMenu(allowed func(cmd commands.Command) bool) []tgbotapi.BotCommand {
+	names := make([]string, 0, len(b.config.Aliases))
+	for name := range b.config.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var botCommands []tgbotapi.BotCommand
+	for _, name := range names {
+		cmd, ok := b.aliasTarget(name)
+		if !ok || !allowed(cmd) {
+			continue
+		}
+		botCommands = append(botCommands, tgbotapi.BotCommand{
+			Command:     name,
+			Description: "Alias for " + b.config.Aliases[name],
+		})
+	}
+	return botCommands
+}
+
+func (b *Bot) runMacro(req *commands.Request, name string, macro config.Macro) {
+	steps, err := commands.ExpandMacro(name, macro, req.Args, b.parsesArgs)
+	if err != nil {
+		msg := transport.NewMessage(req.ChatID, err.Error())
+		b.replies.Send(msg)
+		return
+	}
+
+	for i, step := range steps {
+		if err := b.runLineAs(req, step, macroStepTimeout); err != nil {
+			if errors.Is(err, errCodeRequired) {
+				err = fmt.Errorf("needs a one-time code, send %s and run the macro again", b.codeCommand())
+			}
+			msg := transport.NewMessage(req.ChatID, fmt.Sprintf("/%s stopped at step %d of %d (%s): %v", name, i+1, len(steps), step, err))
+			b.replies.Send(msg)
+			return
+		}
+	}
+
+	msg := transport.NewMessage(req.ChatID, fmt.Sprintf("/%s finished all %d steps.", name, len(steps)))
+	b.replies.Send(msg)
+}
+
+// parsesArgs reports whether a command line's command splits its
+// arguments, so a value put into it has to be quoted to stay one argument.
+func (b *Bot) parsesArgs(line string) bool {
+	name, args, _, _ := commands.ParseCommand(line, b.telegram.UserName())
+	name, _ = b.expandAlias(name, args)
+	cmd, ok := b.registry.Lookup(name)
+	return !ok || !cmd.Info().RawArgs
+}
+
+// runLineAs runs a command line as parent's user in parent's chat, with the
+// same checks and audit entry as if they had sent it themselves, and waits
+// for it. Macro steps and scheduled commands run this way.
+func (b *Bot) runLineAs(parent *commands.Request, line string, timeout time.Duration) error {
+	name, args, _, ok := commands.ParseCommand(line, b.telegram.UserName())
+	name, args = b.expandAlias(name, args)
+	cmd, found := b.registry.Lookup(name)
+	if !ok || !found {
+		return errors.New("unknown command")
+	}
+
+	req := &commands.Request{
+		Context:  context.Background(),
+		ChatID:   parent.ChatID,
+		UserID:   parent.UserID,
+		UserName: parent.UserName,
+		Command:  cmd.Info().Name,
+		Args:     args,
+		Text:     line,
+		Via:      parent.Via,
+	}
+
+	if !b.canRun(req.UserID, cmd) {
+		b.record(req, audit.OutcomeDenied, nil, time.Now())
+		return errors.New("no access")
+	}
+	if b.config.IsChatAllowed(req.ChatID) && !b.config.ChatAllows(req.ChatID, req.Command) {
+		b.record(req, audit.OutcomeDenied, nil, time.Now())
+		return errors.New("only available in a private chat")
+	}
+	if b.authenticator.Required(req.UserID, req.Command) {
+		b.record(req, audit.OutcomeDenied, errCodeRequired, time.Now())
+		return errCodeRequired
+	}
+
+	cmdErr, err := b.dispatchAndWait(cmd, req, cmd.Handle, timeout)
+	switch {
+	case err != nil:
+		return err
+	case cmdErr != nil:
+		return cmdErr
+	case req.Context.Err() != nil:
+		return errors.New("cancelled")
+	}
+	return nil
+}
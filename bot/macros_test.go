@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"context"
+	"reflect"
+	"remoteadmin/commands"
+	"remoteadmin/config"
+	"testing"
+)
+
+func TestMacroPassesTextToMsgAsTyped(t *testing.T) {
+	b, recorder := newTestBot(t, &config.Config{
+		Macros: map[string]config.Macro{
+			"restart": {Params: []string{"app"}, Steps: []string{"/msg restarting {app}"}},
+		},
+	})
+	console := &popups{}
+	b.SetConsoleHandler(console)
+
+	req := &commands.Request{Context: context.Background(), ChatID: 7, UserID: testUserID, UserName: "tester", Command: "restart", Args: `"my app"`}
+	b.runMacro(req, "restart", b.config.Macros["restart"])
+
+	if want := "tester: restarting my app"; len(console.shown) != 1 || console.shown[0] != want {
+		t.Errorf("popups = %q, want [%q]", console.shown, want)
+	}
+	messages := recorder.Messages()
+	if last := messages[len(messages)-1].Text; last != "/restart finished all 1 steps." {
+		t.Errorf("last reply = %q", last)
+	}
+}
+
+func TestMacroStopsWhenAStepFails(t *testing.T) {
+	b, recorder := newTestBot(t, &config.Config{
+		Macros: map[string]config.Macro{
+			"check": {Steps: []string{"/jobs", "/kill abc", "/jobs"}},
+		},
+	})
+
+	req := &commands.Request{Context: context.Background(), ChatID: 7, UserID: testUserID, UserName: "tester", Command: "check"}
+	b.runMacro(req, "check", b.config.Macros["check"])
+
+	var got []string
+	for _, msg := range recorder.Messages() {
+		got = append(got, msg.Text)
+	}
+	want := []string{
+		"No jobs",
+		"Invalid PID. Please provide a valid number.",
+		"/check stopped at step 2 of 3 (/kill abc): Invalid PID. Please provide a valid number.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replies = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"remoteadmin/hardware"
+	"remoteadmin/metrics"
+	"sync"
+	"time"
+)
+
+var registerHostGauges sync.Once
+
+// startMetrics serves /metrics on the configured localhost address.
+func (b *Bot) startMetrics() error {
+	cfg := b.config.Metrics
+	if !cfg.Enabled {
+		return nil
+	}
+
+	registerHostGauges.Do(func() {
+		usage := newUsageCache(time.Second)
+
+		metrics.Default.NewGaugeFunc("remoteadmin_host_cpu_percent", "Host CPU usage.", func() []metrics.Sample {
+			return []metrics.Sample{{Value: usage.get().CPUPercent}}
+		})
+		metrics.Default.NewGaugeFunc("remoteadmin_host_memory_bytes", "Host memory.", func() []metrics.Sample {
+			u := usage.get()
+			return []metrics.Sample{
+				{Values: []string{"total"}, Value: float64(u.MemoryTotal)},
+				{Values: []string{"used"}, Value: float64(u.MemoryUsed)},
+			}
+		}, "state")
+		metrics.Default.NewGaugeFunc("remoteadmin_host_disk_bytes", "Host disk space per mount point.", func() []metrics.Sample {
+			var samples []metrics.Sample
+			for _, disk := range usage.get().Disks {
+				samples = append(samples,
+					metrics.Sample{Values: []string{disk.Mountpoint, "total"}, Value: float64(disk.Total)},
+					metrics.Sample{Values: []string{disk.Mountpoint, "used"}, Value: float64(disk.Used)},
+				)
+			}
+			return samples
+		}, "mountpoint", "state")
+		metrics.Default.NewGaugeFunc("remoteadmin_uptime_seconds", "Time since the bot started.", func() []metrics.Sample {
+			return []metrics.Sample{{Value: time.Since(b.startTime).Seconds()}}
+		})
+	})
+
+	listener, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default)
+
+	b.metricsServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := b.metricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics listener stopped", "error", err)
+		}
+	}()
+
+	slog.Info("metrics listening", "addr", cfg.ListenAddr)
+	return nil
+}
+
+func (b *Bot) stopMetrics() {
+	if b.metricsServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := b.metricsServer.Shutdown(ctx); err != nil {
+		slog.Warn("failed to stop metrics listener", "error", err)
+	}
+}
+
+// usageCache shares one host reading between the gauges of a scrape.
+type usageCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	taken   time.Time
+	current hardware.Usage
+}
+
+func newUsageCache(ttl time.Duration) *usageCache {
+	return &usageCache{ttl: ttl}
+}
+
+func (c *usageCache) get() hardware.Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.taken) > c.ttl {
+		c.current = hardware.GetUsage()
+		c.taken = time.Now()
+	}
+	return c.current
+}
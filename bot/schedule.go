@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"remoteadmin/audit"
+	"remoteadmin/commands"
+	"remoteadmin/schedule"
+	"remoteadmin/transport"
+	"time"
+)
+
+const (
+	scheduleVia = "schedule"
+
+	// scheduledRunTimeout bounds how long a scheduled command may take.
+	scheduledRunTimeout = 30 * time.Minute
+)
+
+// checkScheduled says why userID may not have line run unattended in
+// chatID. Output may go to the user's own chat or to an allowed group that
+// permits the command.
+func (b *Bot) checkScheduled(userID, chatID int64, line string) error {
+	name, args, _, ok := commands.ParseCommand(line, b.telegram.UserName())
+	name, _ = b.expandAlias(name, args)
+	cmd, found := b.registry.Lookup(name)
+	if !ok || !found {
+		return errors.New("unknown command")
+	}
+	info := cmd.Info()
+
+	if info.Name == "schedule" {
+		return errors.New("schedules cannot manage schedules")
+	}
+	if !b.canRun(userID, cmd) {
+		return fmt.Errorf("your role cannot run /%s", info.Name)
+	}
+	if chatID != userID {
+		if !b.config.IsChatAllowed(chatID) {
+			return fmt.Errorf("chat %d is not one the bot answers in", chatID)
+		}
+		if !b.config.ChatAllows(chatID, info.Name) {
+			return fmt.Errorf("/%s is not allowed in chat %d", info.Name, chatID)
+		}
+	}
+	if b.config.IsSensitive(info.Name) && b.authenticator.Enrolled(userID) {
+		return fmt.Errorf("/%s needs a one-time code every time, so it cannot run unattended", info.Name)
+	}
+
+	return nil
+}
+
+// runScheduled runs a schedule as its creator. Failures are posted to the
+// schedule's chat, or to the creator when the chat itself is the problem;
+// schedules of users who lost access only fail in the log.
+func (b *Bot) runScheduled(sched schedule.Schedule) {
+	req := &commands.Request{
+		Context:  context.Background(),
+		ChatID:   sched.ChatID,
+		UserID:   sched.UserID,
+		UserName: sched.UserName,
+		Text:     sched.Command,
+		Via:      scheduleVia,
+	}
+
+	notify := sched.ChatID
+	err := b.checkScheduled(sched.UserID, sched.ChatID, sched.Command)
+	if err != nil {
+		req.Command, req.Args, _, _ = commands.ParseCommand(sched.Command, b.telegram.UserName())
+		b.record(req, audit.OutcomeDenied, err, time.Now())
+		notify = sched.UserID
+	} else {
+		err = b.runLineAs(req, sched.Command, scheduledRunTimeout)
+	}
+	if err == nil {
+		return
+	}
+
+	slog.Warn("scheduled command failed", "id", sched.ID, "command", sched.Command, "user_id", sched.UserID, "error", err)
+	if !b.config.IsAuthorized(sched.UserID) {
+		return
+	}
+	msg := transport.NewMessage(notify, fmt.Sprintf("Schedule #%d (%s) failed: %v", sched.ID, sched.Command, err))
+	b.replies.Send(msg)
+}
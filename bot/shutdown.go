@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"remoteadmin/commands"
+	"time"
+)
+
+// Shutdown stops taking updates, cancels running jobs, waits for queued
+// commands up to the configured timeout and cleans up. It is safe to call
+// from several places; later callers block until the first one finishes.
+func (b *Bot) Shutdown() {
+	b.shutdownOnce.Do(func() {
+		fmt.Println("> Shutting down...")
+		slog.Info("shutting down")
+
+		if err := b.Stop(); err != nil {
+			slog.Warn("failed to stop receiving updates", "error", err)
+		}
+
+		b.scheduler.Stop()
+		if b.fleet != nil {
+			b.fleet.Close()
+		}
+		b.stopDashboard()
+		b.stopAPI()
+		b.stopMetrics()
+		b.jobs.CancelAll()
+		b.browserKiller.Stop()
+
+		drained := make(chan struct{})
+		go func() {
+			b.pool.Close()
+			close(drained)
+		}()
+
+		select {
+		case <-drained:
+		case <-time.After(b.config.ShutdownTimeout()):
+			slog.Warn("some commands were still running at shutdown", "timeout", b.config.ShutdownTimeout())
+		}
+
+		if err := commands.RemoveTempFiles(); err != nil {
+			slog.Warn("failed to remove temp files", "error", err)
+		}
+
+		if err := b.audit.Close(); err != nil {
+			slog.Warn("failed to close audit log", "error", err)
+		}
+
+		hostname, _ := os.Hostname()
+		b.SendMessageToAllAdmins(fmt.Sprintf("Bot on %s is shutting down.", hostname))
+		b.outbox.Close(b.config.ShutdownTimeout())
+		b.messenger.Close()
+		if b.fleetAgent != nil {
+			b.fleetAgent.Close()
+		}
+
+		slog.Info("shutdown complete")
+		fmt.Println("> Goodbye!")
+	})
+}
@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"errors"
+	"remoteadmin/transport"
+	"sync"
+	"time"
+)
+
+var errCodeRequired = errors.New("two-factor code required")
+
+// heldCommand is a sensitive command waiting for its user's TOTP code.
+type heldCommand struct {
+	run     func()
+	expires time.Time
+}
+
+type heldCommands struct {
+	mu       sync.Mutex
+	commands map[int64]heldCommand
+}
+
+// holdForCode parks run until the user sends a valid code, if command needs
+// one. It reports whether the command was held.
+func (b *Bot) holdForCode(chatID, userID int64, command string, run func()) bool {
+	if !b.authenticator.Required(userID, command) {
+		return false
+	}
+
+	b.held.mu.Lock()
+	b.held.commands[userID] = heldCommand{
+		run:     run,
+		expires: time.Now().Add(b.config.ConfirmTimeout()),
+	}
+	b.held.mu.Unlock()
+
+	msg := transport.NewMessage(chatID, "/"+command+" needs a one-time code. Send "+b.codeCommand()+" to continue.")
+	b.replies.Send(msg)
+	return true
+}
+
+// codeCommand is what the user sends to unlock a held command. In a fleet
+// it names this host, since a plain /totp goes wherever /use points.
+func (b *Bot) codeCommand() string {
+	if b.config.Fleet.Role == "" {
+		return "/totp <code>"
+	}
+	return "@" + b.config.Fleet.Name + " /totp <code>"
+}
+
+func (b *Bot) resumeHeld(userID int64) {
+	b.held.mu.Lock()
+	held, ok := b.held.commands[userID]
+	delete(b.held.commands, userID)
+	b.held.mu.Unlock()
+
+	if ok && time.Now().Before(held.expires) {
+		held.run()
+	}
+}
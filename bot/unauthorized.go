@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"remoteadmin/audit"
+	"remoteadmin/commands"
+	"remoteadmin/guard"
+	"remoteadmin/outbox"
+	"remoteadmin/transport"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleUnauthorized records the attempt and returns true if the bot should
+// still reply. Locked out and banned users get no reply at all.
+func (b *Bot) handleUnauthorized(chatID int64, user *tgbotapi.User, command string) bool {
+	attempt := guard.Attempt{
+		UserID:   user.ID,
+		UserName: user.UserName,
+		Name:     displayName(user),
+		Command:  command,
+		Time:     time.Now(),
+	}
+
+	verdict := b.guard.Record(attempt)
+
+	b.record(&commands.Request{
+		ChatID:   chatID,
+		UserID:   attempt.UserID,
+		UserName: attempt.Name,
+		Command:  "unauthorized",
+		Args:     attempt.Command,
+	}, audit.OutcomeRejected, nil, attempt.Time)
+
+	slog.Warn("unauthorized access attempt",
+		"user_id", attempt.UserID,
+		"username", attempt.UserName,
+		"name", attempt.Name,
+		"command", attempt.Command,
+		"attempts", verdict.Attempts,
+		"locked_out", verdict.LockedOut,
+		"banned", verdict.Banned)
+
+	if verdict.Alert {
+		b.messageHandler.NotifyAdmins(outbox.Note{
+			Key:     "unauthorized",
+			Text:    unauthorizedAlert(attempt, verdict),
+			Summary: "%d unauthorized access alerts since the last one went out",
+		})
+	}
+
+	return verdict.Reply
+}
+
+func unauthorizedAlert(attempt guard.Attempt, verdict guard.Verdict) string {
+	text := fmt.Sprintf("Unauthorized access attempt\nUser ID: %d\nUsername: @%s\nName: %s\nCommand: %s",
+		attempt.UserID, attempt.UserName, attempt.Name, attempt.Command)
+
+	switch {
+	case verdict.Banned:
+		text += fmt.Sprintf("\n\nUser is now permanently ignored. Use /unblock %d to undo.", attempt.UserID)
+	case verdict.LockedOut:
+		text += fmt.Sprintf("\n\nUser is temporarily ignored after %d attempts.", verdict.Attempts)
+	}
+
+	return text
+}
+
+func (b *Bot) replyNoAccess(chatID int64) {
+	msg := transport.NewMessage(chatID, "No access.")
+	b.messenger.Send(msg)
+}
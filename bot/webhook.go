@@ -0,0 +1,137 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// updates is never closed, since a handler may still be sending on it while
+// the server shuts down; stopped tells both sides to give up instead.
+type webhookServer struct {
+	api     *tgbotapi.BotAPI
+	server  *http.Server
+	updates chan tgbotapi.Update
+	stopped chan struct{}
+	secret  string
+}
+
+func (b *Bot) startWebhook() (tgbotapi.UpdatesChannel, error) {
+	cfg := b.config.Webhook
+
+	link, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook url: %v", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhook certificate: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", cfg.ListenAddr, err)
+	}
+
+	wh := &webhookServer{
+		api:     b.api,
+		updates: make(chan tgbotapi.Update, 100),
+		stopped: make(chan struct{}),
+		secret:  cfg.SecretToken,
+	}
+
+	path := link.Path
+	if path == "" {
+		path = "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, wh.handleUpdate)
+
+	wh.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+
+	go func() {
+		if err := wh.server.ServeTLS(listener, "", ""); err != nil && err != http.ErrServerClosed {
+			slog.Error("webhook listener stopped", "error", err)
+		}
+	}()
+
+	params := tgbotapi.Params{"url": link.String()}
+	params.AddNonEmpty("secret_token", cfg.SecretToken)
+	params.AddInterface("allowed_updates", []string{"message", "callback_query"})
+
+	var files []tgbotapi.RequestFile
+	if cfg.UploadCertificate {
+		files = append(files, tgbotapi.RequestFile{
+			Name: "certificate",
+			Data: tgbotapi.FilePath(cfg.CertFile),
+		})
+	}
+
+	if _, err := b.api.UploadFiles("setWebhook", params, files); err != nil {
+		wh.server.Close()
+		return nil, fmt.Errorf("setWebhook failed: %v", err)
+	}
+
+	b.webhook = wh
+	return wh.updates, nil
+}
+
+func (wh *webhookServer) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get(secretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(wh.secret)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	update, err := wh.api.HandleUpdate(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Telegram sends the update again later if it isn't acknowledged.
+	select {
+	case <-wh.stopped:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	select {
+	case wh.updates <- *update:
+		w.WriteHeader(http.StatusOK)
+	case <-wh.stopped:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
+
+func (wh *webhookServer) stop() error {
+	close(wh.stopped)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := wh.server.Shutdown(ctx); err != nil {
+		slog.Warn("failed to stop webhook listener", "error", err)
+	}
+
+	_, err := wh.api.Request(tgbotapi.DeleteWebhookConfig{})
+	return err
+}
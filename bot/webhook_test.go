@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestWebhookHandlerGivesUpWhenStopped(t *testing.T) {
+	wh := &webhookServer{
+		api:     &tgbotapi.BotAPI{},
+		updates: make(chan tgbotapi.Update),
+		stopped: make(chan struct{}),
+		secret:  "secret",
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"update_id": 1}`))
+	req.Header.Set(secretTokenHeader, "secret")
+	w := httptest.NewRecorder()
+
+	// Nobody reads updates, so the handler blocks until stopped closes.
+	done := make(chan struct{})
+	go func() {
+		wh.handleUpdate(w, req)
+		close(done)
+	}()
+	close(wh.stopped)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler still blocked after stop")
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -1,98 +1,106 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"remoteadmin/argparse"
+	"remoteadmin/transport"
 	"runtime"
 	"strings"
 	"time"
-
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type AudioHandler struct {
-	api *tgbotapi.BotAPI
+	messenger transport.Messenger
 }
 
-func NewAudioHandler(api *tgbotapi.BotAPI) *AudioHandler {
+func NewAudioHandler(messenger transport.Messenger) *AudioHandler {
 	return &AudioHandler{
-		api: api,
+		messenger: messenger,
 	}
 }
 
-func (h *AudioHandler) HandleAudioCommand(chatID int64) {
+func (h *AudioHandler) HandleAudioCommand(ctx context.Context, chatID int64, args string) error {
+	flags := argparse.New("audio")
+	duration := flags.Duration("duration", 10*time.Second, time.Second, 5*time.Minute, "Recording length")
+	if _, err := flags.Parse(args); err != nil {
+		return fail(h.messenger, chatID, err.Error())
+	}
+
 	if !h.isFFmpegAvailable() {
-		msg := tgbotapi.NewMessage(chatID, "FFmpeg not found. Audio recording requires FFmpeg.")
-		h.api.Send(msg)
-		return
+		return fail(h.messenger, chatID, "FFmpeg not found. Audio recording requires FFmpeg.")
 	}
 
-	msg := tgbotapi.NewMessage(chatID, "Starting audio recording (10 seconds)...")
-	h.api.Send(msg)
+	msg := transport.NewMessage(chatID, fmt.Sprintf("Starting audio recording (%d seconds)...", int((*duration).Seconds())))
+	h.messenger.Send(msg)
 
-	audioPath, err := h.recordAudio(10)
+	// ffmpeg can leave a partial file behind when it fails or is
+	// cancelled, so every output goes once the reply is done.
+	var outputs []string
+	defer func() {
+		for _, path := range outputs {
+			os.Remove(path)
+		}
+	}()
+
+	audioPath, err := h.recordAudio(ctx, *duration)
+	if audioPath != "" {
+		outputs = append(outputs, audioPath)
+	}
+	if ctx.Err() != nil {
+		msg := transport.NewMessage(chatID, "Audio recording cancelled")
+		h.messenger.Send(msg)
+		return nil
+	}
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Failed to record audio: %v", err))
-		h.api.Send(errorMsg)
-		return
+		return fail(h.messenger, chatID, fmt.Sprintf("Failed to record audio: %v", err))
 	}
 
 	fileInfo, err := os.Stat(audioPath)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to get audio file info")
-		h.api.Send(errorMsg)
-		os.Remove(audioPath)
-		return
+		return fail(h.messenger, chatID, "Failed to get audio file info")
 	}
 
 	fileSizeMB := float64(fileInfo.Size()) / (1024 * 1024)
 
 	if fileSizeMB > 50 {
-		compressedPath, err := h.compressAudio(audioPath)
+		compressedPath, err := h.compressAudio(ctx, audioPath)
+		outputs = append(outputs, compressedPath)
+		if ctx.Err() != nil {
+			msg := transport.NewMessage(chatID, "Audio compression cancelled")
+			h.messenger.Send(msg)
+			return nil
+		}
 		if err != nil {
-			errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Audio too large (%.1fMB) and compression failed: %v", fileSizeMB, err))
-			h.api.Send(errorMsg)
-			os.Remove(audioPath)
-			return
+			return fail(h.messenger, chatID, fmt.Sprintf("Audio too large (%.1fMB) and compression failed: %v", fileSizeMB, err))
 		}
 
 		compressedInfo, err := os.Stat(compressedPath)
 		if err != nil {
-			errorMsg := tgbotapi.NewMessage(chatID, "Failed to get compressed audio info")
-			h.api.Send(errorMsg)
-			os.Remove(audioPath)
-			os.Remove(compressedPath)
-			return
+			return fail(h.messenger, chatID, "Failed to get compressed audio info")
 		}
 
 		compressedSizeMB := float64(compressedInfo.Size()) / (1024 * 1024)
 		if compressedSizeMB > 50 {
-			errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Audio still too large after compression (%.1fMB). Try recording for a shorter duration.", compressedSizeMB))
-			h.api.Send(errorMsg)
-			os.Remove(audioPath)
-			os.Remove(compressedPath)
-			return
+			return fail(h.messenger, chatID, fmt.Sprintf("Audio still too large after compression (%.1fMB). Try recording for a shorter duration.", compressedSizeMB))
 		}
 
-		os.Remove(audioPath)
 		audioPath = compressedPath
 		fileSizeMB = compressedSizeMB
 	}
 
-	audio := tgbotapi.NewAudio(chatID, tgbotapi.FilePath(audioPath))
+	audio := transport.NewAudio(chatID, audioPath)
 	audio.Caption = fmt.Sprintf("Audio Recording (%.1fMB)", fileSizeMB)
-	_, err = h.api.Send(audio)
-	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Failed to send audio: %v", err))
-		h.api.Send(errorMsg)
-	} else {
-		successMsg := tgbotapi.NewMessage(chatID, "Audio sent successfully")
-		h.api.Send(successMsg)
+	if err := h.messenger.SendFile(audio); err != nil {
+		return fail(h.messenger, chatID, fmt.Sprintf("Failed to send audio: %v", err))
 	}
 
-	os.Remove(audioPath)
+	successMsg := transport.NewMessage(chatID, "Audio sent successfully")
+	h.messenger.Send(successMsg)
+	return nil
 }
 
 func (h *AudioHandler) isFFmpegAvailable() bool {
@@ -100,8 +108,8 @@ func (h *AudioHandler) isFFmpegAvailable() bool {
 	return err == nil
 }
 
-func (h *AudioHandler) recordAudio(duration int) (string, error) {
-	audioDir := os.TempDir()
+func (h *AudioHandler) recordAudio(ctx context.Context, duration time.Duration) (string, error) {
+	audioDir := tempDir()
 	if err := os.MkdirAll(audioDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create temp directory: %v", err)
 	}
@@ -110,13 +118,17 @@ func (h *AudioHandler) recordAudio(duration int) (string, error) {
 	filename := fmt.Sprintf("audio_recording_%s.wav", timestamp)
 	audioPath := filepath.Join(audioDir, filename)
 
-	audioInputs := h.getAudioInputs()
+	audioInputs := h.getAudioInputs(fmt.Sprintf("%.0f", duration.Seconds()))
 
 	for _, input := range audioInputs {
-		cmd := exec.Command("ffmpeg", input...)
+		if err := ctx.Err(); err != nil {
+			return audioPath, err
+		}
+
+		cmd := exec.CommandContext(ctx, "ffmpeg", input...)
 		cmd.Args = append(cmd.Args, audioPath)
 
-		err := cmd.Run()
+		err := runFFmpeg(ctx, cmd, "audio_record")
 		if err == nil {
 			if fileInfo, statErr := os.Stat(audioPath); statErr == nil && fileInfo.Size() > 0 {
 				return audioPath, nil
@@ -124,12 +136,11 @@ func (h *AudioHandler) recordAudio(duration int) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("failed to record audio with any available input method")
+	return audioPath, fmt.Errorf("failed to record audio with any available input method")
 }
 
-func (h *AudioHandler) getAudioInputs() [][]string {
+func (h *AudioHandler) getAudioInputs(duration string) [][]string {
 	var inputs [][]string
-	duration := "10"
 
 	devices := h.getAvailableAudioDevices()
 
@@ -279,13 +290,13 @@ func (h *AudioHandler) getFallbackInputs(duration string) [][]string {
 	return inputs
 }
 
-func (h *AudioHandler) compressAudio(inputPath string) (string, error) {
+func (h *AudioHandler) compressAudio(ctx context.Context, inputPath string) (string, error) {
 	dir := filepath.Dir(inputPath)
 	ext := filepath.Ext(inputPath)
 	name := filepath.Base(inputPath[:len(inputPath)-len(ext)])
 	compressedPath := filepath.Join(dir, name+"_compressed.mp3")
 
-	cmd := exec.Command("ffmpeg",
+	cmd := exec.CommandContext(ctx, "ffmpeg",
 		"-i", inputPath,
 		"-acodec", "mp3",
 		"-ab", "128k",
@@ -295,9 +306,9 @@ func (h *AudioHandler) compressAudio(inputPath string) (string, error) {
 		compressedPath,
 	)
 
-	err := cmd.Run()
+	err := runFFmpeg(ctx, cmd, "audio_compress")
 	if err != nil {
-		return "", fmt.Errorf("audio compression failed: %v", err)
+		return compressedPath, fmt.Errorf("audio compression failed: %v", err)
 	}
 
 	return compressedPath, nil
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"remoteadmin/argparse"
+	"remoteadmin/audit"
+	"remoteadmin/transport"
+	"strings"
+)
+
+const auditLimit = 20
+
+type AuditHandler struct {
+	messenger transport.Messenger
+	log       *audit.Log
+}
+
+func NewAuditHandler(messenger transport.Messenger, log *audit.Log) *AuditHandler {
+	return &AuditHandler{
+		messenger: messenger,
+		log:       log,
+	}
+}
+
+func (h *AuditHandler) HandleAuditCommand(chatID int64, args string) error {
+	flags := argparse.New("audit")
+	flags.Arg("user", false)
+	flags.Arg("since", false)
+	words, err := flags.Parse(args)
+	if err == nil {
+		var filter audit.Filter
+		filter, err = audit.ParseFilter(words)
+		if err == nil {
+			return h.showEntries(chatID, filter)
+		}
+	}
+
+	return fail(h.messenger, chatID, fmt.Sprintf("%v\nExample: /audit 123456789 2h", err))
+}
+
+func (h *AuditHandler) showEntries(chatID int64, filter audit.Filter) error {
+	filter.Limit = auditLimit
+
+	entries, err := h.log.Query(filter)
+	if err != nil {
+		return fail(h.messenger, chatID, fmt.Sprintf("Failed to read audit log: %v", err))
+	}
+
+	if len(entries) == 0 {
+		msg := transport.NewMessage(chatID, "No matching audit entries")
+		h.messenger.Send(msg)
+		return nil
+	}
+
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("Audit Log (last %d):\n\n", len(entries)))
+	for _, entry := range entries {
+		message.WriteString(entry.String())
+		message.WriteString("\n")
+	}
+
+	msg := transport.NewMessage(chatID, message.String())
+	h.messenger.Send(msg)
+	return nil
+}
@@ -3,69 +3,111 @@ package commands
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"remoteadmin/argparse"
 	"remoteadmin/config"
+	"remoteadmin/metrics"
+	"remoteadmin/outbox"
+	"remoteadmin/transport"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/shirou/gopsutil/v3/process"
 )
 
+// maxViolations is how many recent violations BrowserKiller remembers.
+const maxViolations = 100
+
+// Violation is a browser found on a banned site.
+type Violation struct {
+	Time    time.Time
+	Browser string
+	PID     int32
+	Site    string
+	Killed  bool
+	Error   string
+}
+
 type BrowserKiller struct {
-	api         *tgbotapi.BotAPI
+	messenger   transport.Messenger
 	config      *config.Config
+	outbox      *outbox.Outbox
 	bannedSites []string
-	monitoring  bool
+	monitoring  atomic.Bool
 	lastKill    time.Time
+	done        chan struct{}
+	stopOnce    sync.Once
+	wg          sync.WaitGroup
+
+	mu         sync.Mutex
+	violations []Violation
 }
 
 type BannedSitesConfig struct {
 	BannedSites []string `json:"banned_sites"`
 }
 
-func NewBrowserKiller(api *tgbotapi.BotAPI, cfg *config.Config) *BrowserKiller {
+func NewBrowserKiller(messenger transport.Messenger, cfg *config.Config, outbox *outbox.Outbox) *BrowserKiller {
 	bk := &BrowserKiller{
-		api:        api,
-		config:     cfg,
-		monitoring: false,
+		messenger: messenger,
+		config:    cfg,
+		outbox:    outbox,
+		done:      make(chan struct{}),
 	}
 	bk.loadBannedSites()
-	go bk.startAutoMonitoring()
+
+	bk.wg.Add(1)
+	go bk.monitorBrowsers()
+
 	return bk
 }
 
+func (bk *BrowserKiller) Stop() {
+	bk.stopOnce.Do(func() {
+		bk.monitoring.Store(false)
+		close(bk.done)
+	})
+	bk.wg.Wait()
+}
+
 func (bk *BrowserKiller) loadBannedSites() {
 	file, err := os.ReadFile("banned.json")
 	if err != nil {
-		fmt.Printf("Error reading banned.json: %v\n", err)
+		slog.Error("failed to read banned.json", "error", err)
 		return
 	}
 
 	var config BannedSitesConfig
 	err = json.Unmarshal(file, &config)
 	if err != nil {
-		fmt.Printf("Error parsing banned.json: %v\n", err)
+		slog.Error("failed to parse banned.json", "error", err)
 		return
 	}
 
 	bk.bannedSites = config.BannedSites
 }
 
-func (bk *BrowserKiller) HandleBrowserKillerCommand(chatID int64, text string) {
-	parts := strings.Fields(text)
-	if len(parts) < 2 {
-		msg := tgbotapi.NewMessage(chatID, "Browser Killer Commands:\n\n"+
+func (bk *BrowserKiller) HandleBrowserKillerCommand(chatID int64, args string) error {
+	flags := argparse.New("browser")
+	flags.Arg("start|stop|status|list", false)
+	parts, err := flags.Parse(args)
+	if err != nil {
+		return fail(bk.messenger, chatID, err.Error())
+	}
+	if len(parts) == 0 {
+		msg := transport.NewMessage(chatID, "Browser Killer Commands:\n\n"+
 			"/browser start - Start monitoring\n"+
 			"/browser stop - Stop monitoring\n"+
 			"/browser status - Check status\n"+
 			"/browser list - Show banned sites")
-		bk.api.Send(msg)
-		return
+		bk.messenger.Send(msg)
+		return nil
 	}
 
-	command := parts[1]
-	switch command {
+	switch parts[0] {
 	case "start":
 		bk.startMonitoring(chatID)
 	case "stop":
@@ -75,38 +117,54 @@ func (bk *BrowserKiller) HandleBrowserKillerCommand(chatID int64, text string) {
 	case "list":
 		bk.showBannedSites(chatID)
 	default:
-		msg := tgbotapi.NewMessage(chatID, "Unknown command. Use /browser for help.")
-		bk.api.Send(msg)
+		return fail(bk.messenger, chatID, "Unknown command. Use /browser for help.")
 	}
-}
-
-func (bk *BrowserKiller) startAutoMonitoring() {
-	time.Sleep(2 * time.Second)
-	bk.monitoring = true
-	fmt.Println("Browser Killer: Auto-started monitoring")
-	go bk.monitorBrowsers()
+	return nil
 }
 
 func (bk *BrowserKiller) startMonitoring(chatID int64) {
-	bk.monitoring = true
-	msg := tgbotapi.NewMessage(chatID, "Browser monitoring started")
-	bk.api.Send(msg)
-	go bk.monitorBrowsers()
+	bk.monitoring.Store(true)
+	slog.Info("browser monitoring enabled", "chat_id", chatID)
+	msg := transport.NewMessage(chatID, "Browser monitoring started")
+	bk.messenger.Send(msg)
 }
 
 func (bk *BrowserKiller) stopMonitoring(chatID int64) {
-	bk.monitoring = false
-	msg := tgbotapi.NewMessage(chatID, "Browser monitoring stopped")
-	bk.api.Send(msg)
+	bk.monitoring.Store(false)
+	slog.Info("browser monitoring disabled", "chat_id", chatID)
+	msg := transport.NewMessage(chatID, "Browser monitoring stopped")
+	bk.messenger.Send(msg)
 }
 
 func (bk *BrowserKiller) showStatus(chatID int64) {
+	bk.messenger.Send(bk.statusMessage(chatID))
+}
+
+func (bk *BrowserKiller) HandleBrowserKillerCallback(chatID int64, messageID int, payload string) {
+	switch payload {
+	case "start":
+		bk.monitoring.Store(true)
+		slog.Info("browser monitoring enabled", "chat_id", chatID)
+	case "stop":
+		bk.monitoring.Store(false)
+		slog.Info("browser monitoring disabled", "chat_id", chatID)
+	}
+
+	bk.messenger.Edit(messageID, bk.statusMessage(chatID))
+}
+
+func (bk *BrowserKiller) statusMessage(chatID int64) transport.Message {
 	status := "Stopped"
-	if bk.monitoring {
+	if bk.monitoring.Load() {
 		status = "Running"
 	}
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Browser Killer Status:\nMonitoring: %s\nBanned sites: %d", status, len(bk.bannedSites)))
-	bk.api.Send(msg)
+
+	msg := transport.NewMessage(chatID, fmt.Sprintf("Browser Killer Status:\nMonitoring: %s\nBanned sites: %d", status, len(bk.bannedSites)))
+	msg.Keyboard = transport.NewKeyboard(transport.NewRow(
+		transport.NewButton("Start", CallbackData("browser", "start")),
+		transport.NewButton("Stop", CallbackData("browser", "stop")),
+	))
+	return msg
 }
 
 func (bk *BrowserKiller) showBannedSites(chatID int64) {
@@ -121,17 +179,33 @@ func (bk *BrowserKiller) showBannedSites(chatID int64) {
 		message.WriteString("No sites banned")
 	}
 
-	msg := tgbotapi.NewMessage(chatID, message.String())
-	bk.api.Send(msg)
+	msg := transport.NewMessage(chatID, message.String())
+	bk.messenger.Send(msg)
 }
 
 func (bk *BrowserKiller) monitorBrowsers() {
+	defer bk.wg.Done()
+
+	select {
+	case <-time.After(2 * time.Second):
+	case <-bk.done:
+		return
+	}
+
+	bk.monitoring.Store(true)
+	slog.Info("browser monitor started", "banned_sites", len(bk.bannedSites))
+
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		if bk.monitoring {
-			bk.checkAndKillBrowsers()
+	for {
+		select {
+		case <-ticker.C:
+			if bk.monitoring.Load() {
+				bk.checkAndKillBrowsers()
+			}
+		case <-bk.done:
+			return
 		}
 	}
 }
@@ -139,14 +213,15 @@ func (bk *BrowserKiller) monitorBrowsers() {
 func (bk *BrowserKiller) checkAndKillBrowsers() {
 	processes, err := process.Processes()
 	if err != nil {
+		slog.Warn("browser monitor failed to list processes", "error", err)
 		return
 	}
 
 	browserProcesses := bk.getBrowserProcesses(processes)
 
 	for _, proc := range browserProcesses {
-		if bk.isBannedSiteOpen(proc) {
-			bk.killBrowserProcess(proc)
+		if site, ok := bk.bannedSiteOpen(proc); ok {
+			bk.killBrowserProcess(proc, site)
 		}
 	}
 }
@@ -178,10 +253,11 @@ func (bk *BrowserKiller) getBrowserProcesses(processes []*process.Process) []*pr
 	return browsers
 }
 
-func (bk *BrowserKiller) isBannedSiteOpen(proc *process.Process) bool {
+// bannedSiteOpen returns the banned site a browser process has open, if any.
+func (bk *BrowserKiller) bannedSiteOpen(proc *process.Process) (string, bool) {
 	title, err := proc.Name()
 	if err != nil {
-		return false
+		return "", false
 	}
 
 	cmdline, err := proc.Cmdline()
@@ -198,32 +274,81 @@ func (bk *BrowserKiller) isBannedSiteOpen(proc *process.Process) bool {
 		siteLower = strings.TrimPrefix(siteLower, "www.")
 
 		if strings.Contains(combined, siteLower) {
-			return true
+			return site, true
 		}
 	}
 
-	return false
+	return "", false
 }
 
-func (bk *BrowserKiller) killBrowserProcess(proc *process.Process) {
+func (bk *BrowserKiller) killBrowserProcess(proc *process.Process, site string) {
 	name, _ := proc.Name()
 	pid := proc.Pid
 
 	err := proc.Kill()
+	bk.addViolation(Violation{
+		Time:    time.Now(),
+		Browser: name,
+		PID:     pid,
+		Site:    site,
+		Killed:  err == nil,
+		Error:   errorText(err),
+	})
 	if err != nil {
-		fmt.Printf("Failed to kill browser %s (PID: %d): %s\n", name, pid, err.Error())
+		slog.Warn("failed to kill browser", "name", name, "pid", pid, "error", err)
 		return
 	}
 
+	slog.Info("killed browser on banned site", "name", name, "pid", pid)
+	metrics.BrowserKills.Inc(name)
+
 	now := time.Now()
 	if now.Sub(bk.lastKill) > 5*time.Second {
-		fmt.Println("> Nuhuh can't view this")
 		bk.lastKill = now
 
-		go bk.notifyAdmins(fmt.Sprintf("Browser blocked: %s (PID: %d) - Banned site detected", name, pid))
+		bk.notifyAdmins(fmt.Sprintf("Browser blocked: %s (PID: %d) - Banned site detected", name, pid))
+	}
+}
+
+func (bk *BrowserKiller) addViolation(v Violation) {
+	bk.mu.Lock()
+	defer bk.mu.Unlock()
+
+	bk.violations = append(bk.violations, v)
+	if len(bk.violations) > maxViolations {
+		bk.violations = bk.violations[len(bk.violations)-maxViolations:]
 	}
 }
 
+// Violations returns recent violations, newest first.
+func (bk *BrowserKiller) Violations() []Violation {
+	bk.mu.Lock()
+	defer bk.mu.Unlock()
+
+	violations := make([]Violation, len(bk.violations))
+	for i, v := range bk.violations {
+		violations[len(bk.violations)-1-i] = v
+	}
+	return violations
+}
+
+func (bk *BrowserKiller) BannedSites() []string {
+	sites := make([]string, len(bk.bannedSites))
+	copy(sites, bk.bannedSites)
+	return sites
+}
+
+func (bk *BrowserKiller) IsMonitoring() bool {
+	return bk.monitoring.Load()
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
 func (bk *BrowserKiller) IsSiteBanned(site string) bool {
 	siteLower := strings.ToLower(site)
 	for _, bannedSite := range bk.bannedSites {
@@ -238,9 +363,15 @@ func (bk *BrowserKiller) IsSiteBanned(site string) bool {
 	return false
 }
 
+// notifyAdmins queues a block notice. Blocks that pile up before the last
+// notice goes out arrive as one summary.
 func (bk *BrowserKiller) notifyAdmins(message string) {
-	for _, userID := range bk.config.AuthorizedUsers {
-		msg := tgbotapi.NewMessage(userID, message)
-		bk.api.Send(msg)
+	note := outbox.Note{
+		Key:     "browser-block",
+		Text:    message,
+		Summary: "%d browser blocks since the last notice went out",
+	}
+	for _, userID := range bk.config.AuthorizedIDs() {
+		bk.outbox.Add(userID, note)
 	}
 }
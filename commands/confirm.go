@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"fmt"
+	"remoteadmin/transport"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type pendingConfirmation struct {
+	chatID    int64
+	userID    int64
+	messageID int
+	prompt    string
+	action    func()
+	timer     *time.Timer
+}
+
+type Confirmer struct {
+	messenger transport.Messenger
+	timeout   time.Duration
+	mu        sync.Mutex
+	nextID    int
+	pending   map[int]*pendingConfirmation
+}
+
+func NewConfirmer(messenger transport.Messenger, timeout time.Duration) *Confirmer {
+	return &Confirmer{
+		messenger: messenger,
+		timeout:   timeout,
+		pending:   make(map[int]*pendingConfirmation),
+	}
+}
+
+// Ask shows prompt with Confirm/Cancel buttons. action only runs if the same
+// user confirms before the timeout.
+func (c *Confirmer) Ask(chatID, userID int64, prompt string, action func()) {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.mu.Unlock()
+
+	msg := transport.NewMessage(chatID, fmt.Sprintf("%s\n\nThis request expires in %s.", prompt, formatUptime(c.timeout)))
+	msg.Keyboard = transport.NewKeyboard(transport.NewRow(
+		transport.NewButton("Confirm", CallbackData("confirm", fmt.Sprintf("%d:yes", id))),
+		transport.NewButton("Cancel", CallbackData("confirm", fmt.Sprintf("%d:no", id))),
+	))
+
+	messageID, err := c.messenger.Send(msg)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.pending[id] = &pendingConfirmation{
+		chatID:    chatID,
+		userID:    userID,
+		messageID: messageID,
+		prompt:    prompt,
+		action:    action,
+		timer:     time.AfterFunc(c.timeout, func() { c.expire(id) }),
+	}
+	c.mu.Unlock()
+}
+
+func (c *Confirmer) HandleConfirmCallback(chatID, userID int64, payload string) {
+	idStr, answer, _ := strings.Cut(payload, ":")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	pending, ok := c.pending[id]
+	if ok && pending.userID != userID {
+		c.mu.Unlock()
+		msg := transport.NewMessage(chatID, "Only the admin who made this request can answer it.")
+		c.messenger.Send(msg)
+		return
+	}
+	if ok {
+		delete(c.pending, id)
+		pending.timer.Stop()
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		msg := transport.NewMessage(chatID, "This confirmation has expired or was already answered.")
+		c.messenger.Send(msg)
+		return
+	}
+
+	if answer != "yes" {
+		c.messenger.Edit(pending.messageID, transport.NewMessage(pending.chatID, pending.prompt+"\n\nCancelled."))
+		return
+	}
+
+	c.messenger.Edit(pending.messageID, transport.NewMessage(pending.chatID, pending.prompt+"\n\nConfirmed."))
+	pending.action()
+}
+
+func (c *Confirmer) expire(id int) {
+	c.mu.Lock()
+	pending, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+
+	if ok {
+		c.messenger.Edit(pending.messageID, transport.NewMessage(pending.chatID, pending.prompt+"\n\nExpired - nothing was done."))
+	}
+}
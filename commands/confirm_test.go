@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"remoteadmin/transport"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfirmer(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     int64
+		payload    string
+		wantRan    bool
+		wantEdit   string
+		wantNotice string
+	}{
+		{name: "confirmed", userID: 7, payload: "1:yes", wantRan: true, wantEdit: "Confirmed."},
+		{name: "cancelled", userID: 7, payload: "1:no", wantEdit: "Cancelled."},
+		{name: "someone else", userID: 8, payload: "1:yes", wantNotice: "Only the admin who made this request"},
+		{name: "unknown request", userID: 7, payload: "9:yes", wantNotice: "expired or was already answered"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := transport.NewRecorder()
+			c := NewConfirmer(recorder, time.Minute)
+
+			ran := false
+			c.Ask(1, 7, "Kill 1234?", func() { ran = true })
+			c.HandleConfirmCallback(1, tt.userID, tt.payload)
+
+			if ran != tt.wantRan {
+				t.Errorf("action ran = %v, want %v", ran, tt.wantRan)
+			}
+			edits := recorder.Edits()
+			if tt.wantEdit != "" && (len(edits) != 1 || !strings.HasSuffix(edits[0].Text, tt.wantEdit)) {
+				t.Errorf("edits %+v, want one ending in %q", edits, tt.wantEdit)
+			}
+			messages := recorder.Messages()
+			if last := messages[len(messages)-1].Text; tt.wantNotice != "" && !strings.Contains(last, tt.wantNotice) {
+				t.Errorf("last reply %q, want one mentioning %q", last, tt.wantNotice)
+			}
+		})
+	}
+}
+
+func TestConfirmerExpires(t *testing.T) {
+	recorder := transport.NewRecorder()
+	c := NewConfirmer(recorder, 10*time.Millisecond)
+
+	c.Ask(1, 7, "Kill 1234?", func() { t.Error("action ran after expiring") })
+	time.Sleep(50 * time.Millisecond)
+	c.HandleConfirmCallback(1, 7, "1:yes")
+
+	edits := recorder.Edits()
+	if len(edits) != 1 || !strings.Contains(edits[0].Text, "Expired") {
+		t.Errorf("edits %+v, want one saying it expired", edits)
+	}
+}
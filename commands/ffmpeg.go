@@ -0,0 +1,25 @@
+package commands
+
+import (
+	"context"
+	"os/exec"
+	"remoteadmin/metrics"
+	"time"
+)
+
+// runFFmpeg runs cmd and records how long it took under task.
+func runFFmpeg(ctx context.Context, cmd *exec.Cmd, task string) error {
+	started := time.Now()
+	err := cmd.Run()
+
+	result := "ok"
+	switch {
+	case ctx.Err() != nil:
+		result = "cancelled"
+	case err != nil:
+		result = "error"
+	}
+	metrics.FFmpegDuration.Observe(time.Since(started).Seconds(), task, result)
+
+	return err
+}
@@ -2,92 +2,76 @@ package commands
 
 import (
 	"fmt"
-	"io"
 	"mime"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"remoteadmin/config"
+	"remoteadmin/transport"
 	"runtime"
 	"strings"
 	"time"
+)
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+// File uploads are not a slash command, but they are access-checked like
+// one under this name.
+const (
+	FileUploadName = "file"
+	FileUploadRole = config.RoleOperator
 )
 
 type FileHandler struct {
-	api *tgbotapi.BotAPI
+	messenger  transport.Messenger
+	downloader transport.Downloader
 }
 
-func NewFileHandler(api *tgbotapi.BotAPI) *FileHandler {
+func NewFileHandler(messenger transport.Messenger, downloader transport.Downloader) *FileHandler {
 	return &FileHandler{
-		api: api,
+		messenger:  messenger,
+		downloader: downloader,
 	}
 }
 
-func (h *FileHandler) HandleFileCommand(chatID int64, message *tgbotapi.Message) {
-	var file *tgbotapi.Document
-	var fileName string
-	var fileID string
-
-	if message.Document != nil {
-		file = message.Document
-		fileName = file.FileName
-		fileID = file.FileID
-	} else if message.Photo != nil {
-		photos := message.Photo
-		photo := photos[len(photos)-1]
-		file = &tgbotapi.Document{
-			FileID:   photo.FileID,
-			FileName: "photo.jpg",
-		}
-		fileName = "photo.jpg"
-		fileID = photo.FileID
-	} else if message.Video != nil {
-		video := message.Video
-		file = &tgbotapi.Document{
-			FileID:   video.FileID,
-			FileName: "video.mp4",
-		}
-		fileName = "video.mp4"
-		fileID = video.FileID
-	} else {
-		msg := tgbotapi.NewMessage(chatID, "Please send a file, photo, or video")
-		h.api.Send(msg)
+func (h *FileHandler) HandleFileCommand(chatID int64, file transport.Attachment) {
+	fileName := file.FileName
+	fileID := file.FileID
+
+	if fileID == "" {
+		msg := transport.NewMessage(chatID, "Please send a file, photo, or video")
+		h.messenger.Send(msg)
 		return
 	}
 
 	if !h.isAllowedFileType(fileName, file.MimeType) {
-		msg := tgbotapi.NewMessage(chatID, "File type not allowed. Only images, videos, audio, and text files are permitted.")
-		h.api.Send(msg)
+		msg := transport.NewMessage(chatID, "File type not allowed. Only images, videos, audio, and text files are permitted.")
+		h.messenger.Send(msg)
 		return
 	}
 
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Receiving file: %s", fileName))
-	h.api.Send(msg)
+	msg := transport.NewMessage(chatID, fmt.Sprintf("Receiving file: %s", fileName))
+	h.messenger.Send(msg)
 
 	filePath, err := h.downloadFile(fileID, fileName)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Failed to download file: %s", err.Error()))
-		h.api.Send(errorMsg)
+		errorMsg := transport.NewMessage(chatID, fmt.Sprintf("Failed to download file: %s", err.Error()))
+		h.messenger.Send(errorMsg)
 		return
 	}
 
 	err = h.openFile(filePath)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Failed to open file: %s", err.Error()))
-		h.api.Send(errorMsg)
+		errorMsg := transport.NewMessage(chatID, fmt.Sprintf("Failed to open file: %s", err.Error()))
+		h.messenger.Send(errorMsg)
 		os.Remove(filePath)
 		return
 	}
 
-	successMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("File opened successfully: %s\n Path: %s", fileName, filePath))
-	h.api.Send(successMsg)
+	successMsg := transport.NewMessage(chatID, fmt.Sprintf("File opened successfully: %s\n Path: %s", fileName, filePath))
+	h.messenger.Send(successMsg)
 
-	go func() {
-		time.Sleep(30 * time.Second)
+	time.AfterFunc(30*time.Second, func() {
 		os.Remove(filePath)
-	}()
+	})
 }
 
 func (h *FileHandler) isAllowedFileType(fileName, mimeType string) bool {
@@ -137,34 +121,13 @@ func (h *FileHandler) isAllowedFileType(fileName, mimeType string) bool {
 }
 
 func (h *FileHandler) downloadFile(fileID, fileName string) (string, error) {
-	downloadDir := os.TempDir()
-
-	fileResp, err := h.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
-	if err != nil {
-		return "", err
-	}
+	downloadDir := tempDir()
 
 	timestamp := time.Now().Format("20060102_150405")
 	localFileName := fmt.Sprintf("%s_%s", timestamp, fileName)
 	localFilePath := filepath.Join(downloadDir, localFileName)
 
-	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", h.api.Token, fileResp.FilePath)
-
-	resp, err := http.Get(fileURL)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	localFile, err := os.Create(localFilePath)
-	if err != nil {
-		return "", err
-	}
-	defer localFile.Close()
-
-	_, err = io.Copy(localFile, resp.Body)
-	if err != nil {
-		os.Remove(localFilePath)
+	if err := h.downloader.Download(fileID, localFilePath); err != nil {
 		return "", err
 	}
 
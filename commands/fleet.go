@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"fmt"
+	"remoteadmin/argparse"
+	"remoteadmin/fleet"
+	"remoteadmin/format"
+	"remoteadmin/transport"
+	"strings"
+	"time"
+)
+
+type FleetHandler struct {
+	messenger   transport.Messenger
+	coordinator *fleet.Coordinator
+	local       string
+}
+
+// local is the coordinator's own host name.
+func NewFleetHandler(messenger transport.Messenger, coordinator *fleet.Coordinator, local string) *FleetHandler {
+	return &FleetHandler{
+		messenger:   messenger,
+		coordinator: coordinator,
+		local:       local,
+	}
+}
+
+func (h *FleetHandler) HandleHostsCommand(chatID, userID int64) {
+	selected := h.coordinator.Selected(userID)
+	marker := func(name string) string {
+		if strings.EqualFold(name, selected) || selected == "" && name == h.local {
+			return "  <- /use"
+		}
+		return ""
+	}
+
+	doc := format.NewDoc()
+	doc.Line(format.Bold("Hosts")).Blank()
+	doc.Line(format.Bold(h.local), format.Text(" (coordinator)"+marker(h.local)))
+
+	hosts := h.coordinator.Hosts()
+	for _, host := range hosts {
+		doc.Line(format.Bold(host.Name), format.Text(marker(host.Name)))
+		doc.Line(format.Textf("   %s, connected %s ago, last seen %s ago", host.Addr, formatUptime(time.Since(host.Connected)), formatUptime(time.Since(host.LastSeen))))
+	}
+	if len(hosts) == 0 {
+		doc.Line(format.Text("No agents connected."))
+	}
+	if selected != "" {
+		if _, ok := h.coordinator.Lookup(selected); !ok {
+			doc.Blank().Line(format.Textf("Your commands go to %s, which is not connected.", selected))
+		}
+	}
+
+	doc.Blank().Line(format.Text("Use /use <host> to pick one, or start a command with @host."))
+	h.messenger.Send(doc.Message(chatID))
+}
+
+func (h *FleetHandler) HandleUseCommand(chatID, userID int64, args string) error {
+	flags := argparse.New("use")
+	flags.Arg("host", false)
+	words, err := flags.Parse(args)
+	if err != nil {
+		return fail(h.messenger, chatID, err.Error())
+	}
+
+	if len(words) == 0 {
+		current := h.coordinator.Selected(userID)
+		if current == "" {
+			current = h.local
+		}
+		h.reply(chatID, fmt.Sprintf("Your commands go to %s. /hosts lists the others.", current))
+		return nil
+	}
+
+	name := strings.TrimPrefix(words[0], "@")
+	if strings.EqualFold(name, h.local) {
+		h.coordinator.Select(userID, "")
+		h.reply(chatID, fmt.Sprintf("Your commands go to %s again.", h.local))
+		return nil
+	}
+
+	host, ok := h.coordinator.Lookup(name)
+	if !ok {
+		return fail(h.messenger, chatID, fmt.Sprintf("%s is not connected. /hosts lists the hosts that are.", name))
+	}
+	h.coordinator.Select(userID, host.Name)
+	h.reply(chatID, fmt.Sprintf("Your commands now go to %s. Use /use %s to come back.", host.Name, h.local))
+	return nil
+}
+
+func (h *FleetHandler) reply(chatID int64, text string) {
+	msg := transport.NewMessage(chatID, text)
+	h.messenger.Send(msg)
+}
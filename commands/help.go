@@ -1,62 +1,118 @@
 package commands
 
 import (
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"remoteadmin/config"
+	"remoteadmin/transport"
+	"sort"
+	"strings"
 )
 
 type HelpHandler struct {
-	api *tgbotapi.BotAPI
+	messenger transport.Messenger
+	registry  *Registry
+	config    *config.Config
 }
 
-func NewHelpHandler(api *tgbotapi.BotAPI) *HelpHandler {
+func NewHelpHandler(messenger transport.Messenger, registry *Registry, cfg *config.Config) *HelpHandler {
 	return &HelpHandler{
-		api: api,
+		messenger: messenger,
+		registry:  registry,
+		config:    cfg,
 	}
 }
 
-func (h *HelpHandler) HandleHelpCommand(chatID int64) {
-	helpText := `**Remote Admin Bot - Commands**
+func (h *HelpHandler) HandleHelpCommand(chatID, userID int64) {
+	msg := transport.NewMessage(chatID, h.GetHelpText(userID))
+	msg.ParseMode = transport.ModeMarkdown
+	h.messenger.Send(msg)
+}
+
+func (h *HelpHandler) GetHelpText(userID int64) string {
+	var categories []string
+	lines := make(map[string][]string)
 
-**System Information:**
-• /info - System hardware and software information
-• /displays - Display information and resolutions
+	for _, cmd := range h.registry.Commands() {
+		info := cmd.Info()
+		if info.Hidden || !h.config.CanRun(userID, info.Name, info.Role) {
+			continue
+		}
 
-**Screenshots & Recording:**
-• /ss - Screenshot each monitor separately
-• /ssa - Screenshot all monitors as one image
-• /ssm - Screenshot main monitor only
-• /vid - Record 5-second video of main monitor (max 50MB)
-• /audio - Record 10-second audio from microphone (max 50MB)
+		category := info.Category
+		if category == "" {
+			category = "Other"
+		}
 
-**Process Management:**
-• /processes - List running applications
-• /kill <PID> - Kill a process by PID
+		if _, seen := lines[category]; !seen {
+			categories = append(categories, category)
+		}
+
+		usage := info.Usage
+		if usage == "" {
+			usage = "/" + info.Name
+		}
+		line := "• " + usage + " - " + info.Description
+		if len(info.Aliases) > 0 {
+			line += " (also /" + strings.Join(info.Aliases, ", /") + ")"
+		}
+		lines[category] = append(lines[category], line)
+	}
 
-**Browser Killer:**
-• /browser start - Start monitoring
-• /browser stop - Stop monitoring  
-• /browser status - Check status
-• /browser list - Show banned sites
+	var helpText strings.Builder
+	helpText.WriteString("**Remote Admin Bot - Commands**\n\n")
 
-**Communication:**
-• /msg "message" - Send message to console
-• /help - Show this help menu
+	for _, category := range categories {
+		helpText.WriteString("**" + category + ":**\n")
+		for _, line := range lines[category] {
+			helpText.WriteString(line + "\n")
+		}
+		helpText.WriteString("\n")
+	}
+
+	if aliases := h.aliasLines(userID); len(aliases) > 0 {
+		helpText.WriteString("**Aliases:**\n")
+		for _, line := range aliases {
+			helpText.WriteString(line + "\n")
+		}
+		helpText.WriteString("\n")
+	}
 
-**File Management:**
+	if h.config.CanRun(userID, FileUploadName, FileUploadRole) {
+		helpText.WriteString(`**File Uploads:**
 • Send any file as document - Auto-open on this computer
-• /files - Show supported file types
 
-**Console Commands:**
+`)
+	}
+
+	helpText.WriteString(`**Console Commands:**
 • 1 - Ping admin
 • 2 - Send message
 • 3 - Show help
 • 4 - Exit program
 
-*All commands require authorization.*`
+*Only commands your role allows are listed.*`)
+
+	return helpText.String()
+}
 
-	msg := tgbotapi.NewMessage(chatID, helpText)
-	msg.ParseMode = "Markdown"
-	h.api.Send(msg)
+// aliasLines lists the aliases whose target command the user can run.
+func (h *HelpHandler) aliasLines(userID int64) []string {
+	names := make([]string, 0, len(h.config.Aliases))
+	for name := range h.config.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		target := h.config.Aliases[name]
+		targetName, _, _, _ := ParseCommand(target, "")
+		cmd, ok := h.registry.Lookup(targetName)
+		if !ok || !h.config.CanRun(userID, cmd.Info().Name, cmd.Info().Role) {
+			continue
+		}
+		lines = append(lines, "• /"+name+" - "+target)
+	}
+	return lines
 }
 
 func (h *HelpHandler) GetStartMessage() string {
@@ -4,24 +4,26 @@ import (
 	"fmt"
 	"os"
 	"remoteadmin/config"
+	"remoteadmin/format"
 	"remoteadmin/hardware"
+	"remoteadmin/transport"
 	"runtime"
 	"time"
-
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type InfoHandler struct {
-	api       *tgbotapi.BotAPI
-	config    *config.Config
-	startTime time.Time
+	messenger   transport.Messenger
+	config      *config.Config
+	startTime   time.Time
+	botUserName string
 }
 
-func NewInfoHandler(api *tgbotapi.BotAPI, cfg *config.Config, startTime time.Time) *InfoHandler {
+func NewInfoHandler(messenger transport.Messenger, cfg *config.Config, startTime time.Time, botUserName string) *InfoHandler {
 	return &InfoHandler{
-		api:       api,
-		config:    cfg,
-		startTime: startTime,
+		messenger:   messenger,
+		config:      cfg,
+		startTime:   startTime,
+		botUserName: botUserName,
 	}
 }
 
@@ -34,33 +36,25 @@ func (h *InfoHandler) HandleInfoCommand(chatID int64) {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 
-	msg1 := tgbotapi.NewMessage(chatID, fmt.Sprintf("Remote Admin Bot Info\n\n%s", hardwareInfo))
-	_, err := h.api.Send(msg1)
+	msg1 := transport.NewMessage(chatID, fmt.Sprintf("Remote Admin Bot Info\n\n%s", hardwareInfo))
+	_, err := h.messenger.Send(msg1)
 	if err != nil {
-		simpleMsg := tgbotapi.NewMessage(chatID, "Remote Admin Bot Info\n\n Error loading hardware information")
-		h.api.Send(simpleMsg)
+		simpleMsg := transport.NewMessage(chatID, fmt.Sprintf("Remote Admin Bot Info\n\nError sending hardware information: %v", err))
+		h.messenger.Send(simpleMsg)
 	}
 
-	botInfo := fmt.Sprintf(`*Bot Information:*
-• Hostname: %s
-• Uptime: %s
-• Authorized Users: %d
-• Bot Username: @%s
-• Process Memory: %d MB
-• Goroutines: %d
-
-*Last Updated:* %s`,
-		hostname,
-		formatUptime(uptime),
-		len(h.config.AuthorizedUsers),
-		h.api.Self.UserName,
-		memStats.Alloc/1024/1024,
-		runtime.NumGoroutine(),
-		time.Now().Format("2006-01-02 15:04:05 MST"))
+	botInfo := format.NewDoc().
+		Line(format.Bold("Bot Information:")).
+		Field("Hostname", hostname).
+		Field("Uptime", formatUptime(uptime)).
+		Field("Authorized Users", len(h.config.AuthorizedIDs())).
+		Field("Bot Username", "@"+h.botUserName).
+		Field("Process Memory", fmt.Sprintf("%d MB", memStats.Alloc/1024/1024)).
+		Field("Goroutines", runtime.NumGoroutine()).
+		Blank().
+		Line(format.Bold("Last Updated:"), format.Text(" "+time.Now().Format("2006-01-02 15:04:05 MST")))
 
-	msg2 := tgbotapi.NewMessage(chatID, botInfo)
-	msg2.ParseMode = "Markdown"
-	h.api.Send(msg2)
+	h.messenger.Send(botInfo.Message(chatID))
 }
 
 func formatUptime(d time.Duration) string {
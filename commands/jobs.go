@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"fmt"
+	"remoteadmin/argparse"
+	"remoteadmin/jobs"
+	"remoteadmin/transport"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type JobsHandler struct {
+	messenger transport.Messenger
+	manager   *jobs.Manager
+}
+
+func NewJobsHandler(messenger transport.Messenger, manager *jobs.Manager) *JobsHandler {
+	return &JobsHandler{
+		messenger: messenger,
+		manager:   manager,
+	}
+}
+
+func (h *JobsHandler) HandleJobsCommand(chatID int64) {
+	list := h.manager.List()
+	if len(list) == 0 {
+		msg := transport.NewMessage(chatID, "No jobs")
+		h.messenger.Send(msg)
+		return
+	}
+
+	var message strings.Builder
+	message.WriteString("Jobs:\n\n")
+
+	for _, job := range list {
+		message.WriteString(fmt.Sprintf("#%d /%s - %s\n", job.ID, job.Name, job.Status))
+		message.WriteString(fmt.Sprintf("   Owner: %s (%d)\n", job.OwnerName, job.Owner))
+
+		switch {
+		case job.Status == jobs.StatusQueued:
+			message.WriteString(fmt.Sprintf("   Queued: %s ago\n", formatUptime(time.Since(job.Created))))
+		case job.Status == jobs.StatusRunning:
+			message.WriteString(fmt.Sprintf("   Started: %s (%s ago)\n", job.Started.Format("15:04:05"), formatUptime(time.Since(job.Started))))
+		case !job.Started.IsZero():
+			message.WriteString(fmt.Sprintf("   Started: %s, took %s\n", job.Started.Format("15:04:05"), formatUptime(job.Finished.Sub(job.Started))))
+		}
+
+		if job.Error != "" {
+			message.WriteString(fmt.Sprintf("   Error: %s\n", job.Error))
+		}
+	}
+
+	message.WriteString("\nUse /cancel <id> to stop a queued or running job.")
+
+	msg := transport.NewMessage(chatID, message.String())
+	h.messenger.Send(msg)
+}
+
+func (h *JobsHandler) HandleCancelCommand(chatID int64, args string) error {
+	flags := argparse.New("cancel")
+	flags.Arg("job id", true)
+	words, err := flags.Parse(args)
+	if err != nil {
+		return fail(h.messenger, chatID, err.Error()+"\nExample: /cancel 3")
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(words[0], "#"))
+	if err != nil {
+		return fail(h.messenger, chatID, "Invalid job ID. Please provide a valid number.")
+	}
+
+	job, err := h.manager.Cancel(id)
+	if err != nil {
+		return fail(h.messenger, chatID, fmt.Sprintf("Cannot cancel job: %s", err.Error()))
+	}
+
+	msg := transport.NewMessage(chatID, fmt.Sprintf("Cancelling job #%d (/%s)", job.ID, job.Name))
+	h.messenger.Send(msg)
+	return nil
+}
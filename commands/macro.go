@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"remoteadmin/argparse"
+	"remoteadmin/config"
+	"strings"
+)
+
+// MacroUsage is the usage line for a macro, e.g. "/restart <name>".
+func MacroUsage(name string, macro config.Macro) string {
+	usage := "/" + name
+	for _, param := range macro.Params {
+		usage += " <" + param + ">"
+	}
+	return usage
+}
+
+// ExpandMacro fills the macro's {param} placeholders from args and returns
+// its steps. In steps for which quote reports true, values are quoted so
+// each one stays a single argument; other steps get them as typed.
+func ExpandMacro(name string, macro config.Macro, args string, quote func(step string) bool) ([]string, error) {
+	flags := argparse.New(name)
+	for _, param := range macro.Params {
+		flags.Arg(param, true)
+	}
+	values, err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw, quoted []string
+	for i, param := range macro.Params {
+		raw = append(raw, "{"+param+"}", values[i])
+		quoted = append(quoted, "{"+param+"}", argparse.Quote(values[i]))
+	}
+	rawReplacer := strings.NewReplacer(raw...)
+	quotedReplacer := strings.NewReplacer(quoted...)
+
+	steps := make([]string, len(macro.Steps))
+	for i, step := range macro.Steps {
+		if quote(step) {
+			steps[i] = quotedReplacer.Replace(step)
+		} else {
+			steps[i] = rawReplacer.Replace(step)
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"reflect"
+	"remoteadmin/config"
+	"strings"
+	"testing"
+)
+
+// quoteArgv quotes values everywhere but in /msg, which takes its text as
+// typed.
+func quoteArgv(step string) bool {
+	return !strings.HasPrefix(step, "/msg ")
+}
+
+func TestExpandMacro(t *testing.T) {
+	restart := config.Macro{
+		Params: []string{"name"},
+		Steps:  []string{"/kill {name}", "/msg restarting {name}"},
+	}
+
+	tests := []struct {
+		name    string
+		macro   config.Macro
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{"no params", config.Macro{Steps: []string{"/info", "/ssm"}}, "", []string{"/info", "/ssm"}, false},
+		{"param", restart, "chrome", []string{`/kill "chrome"`, `/msg restarting chrome`}, false},
+		{"quoted value stays one argument", restart, `"my app"`, []string{`/kill "my app"`, `/msg restarting my app`}, false},
+		{"quotes in the value", restart, `'say "hi"'`, []string{`/kill "say \"hi\""`, `/msg restarting say "hi"`}, false},
+		{"missing param", restart, "", nil, true},
+		{"extra argument", restart, "a b", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandMacro("restart", tt.macro, tt.args, quoteArgv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMacroUsage(t *testing.T) {
+	macro := config.Macro{Params: []string{"pid", "signal"}}
+	if got := MacroUsage("stop", macro); got != "/stop <pid> <signal>" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -2,29 +2,37 @@ package commands
 
 import (
 	"remoteadmin/config"
-
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"remoteadmin/outbox"
+	"remoteadmin/transport"
 )
 
 type MessageHandler struct {
-	api    *tgbotapi.BotAPI
-	config *config.Config
+	messenger transport.Messenger
+	config    *config.Config
+	outbox    *outbox.Outbox
 }
 
-func NewMessageHandler(api *tgbotapi.BotAPI, cfg *config.Config) *MessageHandler {
+func NewMessageHandler(messenger transport.Messenger, cfg *config.Config, outbox *outbox.Outbox) *MessageHandler {
 	return &MessageHandler{
-		api:    api,
-		config: cfg,
+		messenger: messenger,
+		config:    cfg,
+		outbox:    outbox,
 	}
 }
 
 func (h *MessageHandler) SendMessage(chatID int64, text string) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	h.api.Send(msg)
+	msg := transport.NewMessage(chatID, text)
+	h.messenger.Send(msg)
 }
 
+// SendMessageToAllAdmins queues text for every authorized user. It is kept
+// until it can be delivered.
 func (h *MessageHandler) SendMessageToAllAdmins(text string) {
-	for _, userID := range h.config.AuthorizedUsers {
-		h.SendMessage(userID, text)
+	h.NotifyAdmins(outbox.Note{Text: text})
+}
+
+func (h *MessageHandler) NotifyAdmins(note outbox.Note) {
+	for _, userID := range h.config.AuthorizedIDs() {
+		h.outbox.Add(userID, note)
 	}
 }
@@ -1,34 +1,32 @@
 package commands
 
 import (
+	"remoteadmin/transport"
 	"strings"
-
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type MsgHandler struct {
-	api            *tgbotapi.BotAPI
+	messenger      transport.Messenger
 	consoleHandler interface {
 		SendPopup(message string)
 	}
 }
 
-func NewMsgHandler(api *tgbotapi.BotAPI, consoleHandler interface {
+func NewMsgHandler(messenger transport.Messenger, consoleHandler interface {
 	SendPopup(message string)
 }) *MsgHandler {
 	return &MsgHandler{
-		api:            api,
+		messenger:      messenger,
 		consoleHandler: consoleHandler,
 	}
 }
 
-func (h *MsgHandler) HandleMsgCommand(chatID int64, text string, userName string) {
-	message := strings.TrimSpace(strings.TrimPrefix(text, "/msg"))
-
+// The message is taken as typed, so apostrophes and stray quotes are fine;
+// only quotes around the whole message, as in the usage line, are removed.
+func (h *MsgHandler) HandleMsgCommand(chatID int64, args string, userName string) error {
+	message := messageText(args)
 	if message == "" {
-		msg := tgbotapi.NewMessage(chatID, "Usage: /msg \"your message here\"")
-		h.api.Send(msg)
-		return
+		return fail(h.messenger, chatID, "Usage: /msg \"your message here\"")
 	}
 
 	if h.consoleHandler != nil {
@@ -36,6 +34,15 @@ func (h *MsgHandler) HandleMsgCommand(chatID int64, text string, userName string
 		h.consoleHandler.SendPopup(formattedMessage)
 	}
 
-	confirmMsg := tgbotapi.NewMessage(chatID, "Message sent to console!")
-	h.api.Send(confirmMsg)
+	confirmMsg := transport.NewMessage(chatID, "Message sent to console!")
+	h.messenger.Send(confirmMsg)
+	return nil
+}
+
+func messageText(args string) string {
+	message := strings.TrimSpace(args)
+	if len(message) >= 2 && strings.HasPrefix(message, `"`) && strings.HasSuffix(message, `"`) {
+		message = strings.TrimSpace(message[1 : len(message)-1])
+	}
+	return message
 }
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"remoteadmin/transport"
+	"testing"
+)
+
+type popups struct{ got []string }
+
+func (p *popups) SendPopup(message string) { p.got = append(p.got, message) }
+
+func TestHandleMsgCommand(t *testing.T) {
+	tests := []struct {
+		args  string
+		popup string
+	}{
+		{"hello", "ann: hello"},
+		{"don't forget", "ann: don't forget"},
+		{`"quoted message"`, "ann: quoted message"},
+		{`say "hi" to them`, `ann: say "hi" to them`},
+		{"  --not-a-flag  ", "ann: --not-a-flag"},
+		{"", ""},
+		{`""`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			recorder := transport.NewRecorder()
+			console := &popups{}
+			NewMsgHandler(recorder, console).HandleMsgCommand(1, tt.args, "ann")
+
+			if tt.popup == "" {
+				if len(console.got) != 0 {
+					t.Errorf("popup shown for empty message: %q", console.got)
+				}
+				return
+			}
+			if len(console.got) != 1 || console.got[0] != tt.popup {
+				t.Errorf("popups = %q, want %q", console.got, tt.popup)
+			}
+			if messages := recorder.Messages(); len(messages) != 1 || messages[0].Text != "Message sent to console!" {
+				t.Errorf("replies = %+v", messages)
+			}
+		})
+	}
+}
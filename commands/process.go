@@ -2,22 +2,26 @@ package commands
 
 import (
 	"fmt"
+	"remoteadmin/argparse"
+	"remoteadmin/format"
+	"remoteadmin/transport"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/shirou/gopsutil/v3/process"
 )
 
 type ProcessHandler struct {
-	api *tgbotapi.BotAPI
+	messenger transport.Messenger
+	confirmer *Confirmer
 }
 
-func NewProcessHandler(api *tgbotapi.BotAPI) *ProcessHandler {
+func NewProcessHandler(messenger transport.Messenger, confirmer *Confirmer) *ProcessHandler {
 	return &ProcessHandler{
-		api: api,
+		messenger: messenger,
+		confirmer: confirmer,
 	}
 }
 
@@ -31,31 +35,34 @@ type ProcessInfo struct {
 	Command string
 }
 
-func (h *ProcessHandler) HandleProcessCommand(chatID int64) {
+func (h *ProcessHandler) HandleProcessCommand(chatID int64, args string, showKillButtons bool) error {
+	flags := argparse.New("processes")
+	sortBy := flags.Enum("sort", "memory", []string{"memory", "cpu", "name", "pid"}, "Sort order")
+	limit := flags.Int("limit", 15, 1, 50, "How many processes to show")
+	if _, err := flags.Parse(args); err != nil {
+		return fail(h.messenger, chatID, err.Error())
+	}
+
 	processes, err := h.getUserProcesses()
 	if err != nil {
-		msg := tgbotapi.NewMessage(chatID, "Error getting process information")
-		h.api.Send(msg)
-		return
+		return fail(h.messenger, chatID, "Error getting process information")
 	}
 
 	if len(processes) == 0 {
-		msg := tgbotapi.NewMessage(chatID, "No user processes found")
-		h.api.Send(msg)
-		return
+		msg := transport.NewMessage(chatID, "No user processes found")
+		h.messenger.Send(msg)
+		return nil
 	}
 
-	sort.Slice(processes, func(i, j int) bool {
-		return processes[i].Memory > processes[j].Memory
-	})
+	sortProcesses(processes, *sortBy)
 
-	maxProcesses := 15
-	if len(processes) > maxProcesses {
-		processes = processes[:maxProcesses]
+	if len(processes) > *limit {
+		processes = processes[:*limit]
 	}
 
-	var message strings.Builder
-	message.WriteString("**Running Applications**\n\n")
+	doc := format.NewDoc()
+	var rows [][]transport.Button
+	doc.Line(format.Bold("Running Applications")).Blank()
 
 	for i, proc := range processes {
 		status := "+"
@@ -65,44 +72,85 @@ func (h *ProcessHandler) HandleProcessCommand(chatID int64) {
 			status = "*"
 		}
 
-		message.WriteString(fmt.Sprintf("%d. %s **%s** (PID: %d)\n",
-			i+1, status, proc.Name, proc.PID))
-		message.WriteString(fmt.Sprintf("   Memory: %.1f MB | CPU: %.1f%%\n",
-			proc.Memory, proc.CPU))
+		doc.Line(format.Textf("%d. %s ", i+1, status), format.Bold(proc.Name), format.Textf(" (PID: %d)", proc.PID))
+		doc.Line(format.Textf("   Memory: %.1f MB | CPU: %.1f%%", proc.Memory, proc.CPU))
 
 		if proc.Command != "" && len(proc.Command) > 50 {
-			message.WriteString(fmt.Sprintf("   Command: %s...\n", proc.Command[:50]))
+			doc.Line(format.Text("   Command: "), format.Code(proc.Command[:50]+"..."))
 		} else if proc.Command != "" {
-			message.WriteString(fmt.Sprintf("   Command: %s\n", proc.Command))
+			doc.Line(format.Text("   Command: "), format.Code(proc.Command))
+		}
+		doc.Blank()
+
+		if !showKillButtons {
+			continue
 		}
-		message.WriteString("\n")
+		rows = append(rows, transport.NewRow(
+			transport.NewButton(fmt.Sprintf("Kill %s (%d)", proc.Name, proc.PID), CallbackData("kill", strconv.Itoa(int(proc.PID)))),
+		))
 	}
 
-	msg := tgbotapi.NewMessage(chatID, message.String())
-	msg.ParseMode = "Markdown"
-	h.api.Send(msg)
+	msg := doc.Message(chatID)
+	msg.Keyboard = transport.NewKeyboard(rows...)
+	h.messenger.Send(msg)
+	return nil
 }
 
-func (h *ProcessHandler) HandleKillProcessCommand(chatID int64, text string) {
-	parts := strings.Fields(text)
-	if len(parts) < 2 {
-		msg := tgbotapi.NewMessage(chatID, "Usage: /kill <PID>\nExample: /kill 1234")
-		h.api.Send(msg)
-		return
+func (h *ProcessHandler) HandleKillProcessCommand(chatID, userID int64, args string) error {
+	flags := argparse.New("kill")
+	flags.Arg("PID", true)
+	words, err := flags.Parse(args)
+	if err != nil {
+		return fail(h.messenger, chatID, err.Error()+"\nExample: /kill 1234")
 	}
 
-	pidStr := parts[1]
+	return h.confirmKill(chatID, userID, words[0])
+}
+
+func (h *ProcessHandler) HandleKillCallback(chatID, userID int64, payload string) error {
+	return h.confirmKill(chatID, userID, payload)
+}
+
+func (h *ProcessHandler) confirmKill(chatID, userID int64, pidStr string) error {
 	pid, err := strconv.ParseInt(pidStr, 10, 32)
 	if err != nil {
-		msg := tgbotapi.NewMessage(chatID, "Invalid PID. Please provide a valid number.")
-		h.api.Send(msg)
-		return
+		return fail(h.messenger, chatID, "Invalid PID. Please provide a valid number.")
 	}
 
 	proc, err := process.NewProcess(int32(pid))
 	if err != nil {
-		msg := tgbotapi.NewMessage(chatID, "Process not found or access denied.")
-		h.api.Send(msg)
+		return fail(h.messenger, chatID, "Process not found or access denied.")
+	}
+
+	name, _ := proc.Name()
+	username, _ := proc.Username()
+	cmdline, _ := proc.Cmdline()
+	createTime, _ := proc.CreateTime()
+
+	if len(cmdline) > 200 {
+		cmdline = cmdline[:200] + "..."
+	}
+
+	prompt := fmt.Sprintf("Kill this process?\n\nName: %s\nPID: %d\nUser: %s\nCommand: %s", name, pid, username, cmdline)
+
+	h.confirmer.Ask(chatID, userID, prompt, func() {
+		h.killProcess(chatID, int32(pid), createTime)
+	})
+	return nil
+}
+
+func (h *ProcessHandler) killProcess(chatID int64, pid int32, createTime int64) {
+	proc, err := process.NewProcess(pid)
+	if err != nil {
+		msg := transport.NewMessage(chatID, "Process not found or access denied.")
+		h.messenger.Send(msg)
+		return
+	}
+
+	// The PID may have been reused while the prompt was open.
+	if current, _ := proc.CreateTime(); current != createTime {
+		msg := transport.NewMessage(chatID, fmt.Sprintf("Process %d has changed since the request. Nothing was killed.", pid))
+		h.messenger.Send(msg)
 		return
 	}
 
@@ -110,13 +158,28 @@ func (h *ProcessHandler) HandleKillProcessCommand(chatID int64, text string) {
 
 	err = proc.Kill()
 	if err != nil {
-		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Failed to kill process %s (PID: %d)\nError: %s", name, pid, err.Error()))
-		h.api.Send(msg)
+		msg := transport.NewMessage(chatID, fmt.Sprintf("Failed to kill process %s (PID: %d)\nError: %s", name, pid, err.Error()))
+		h.messenger.Send(msg)
 		return
 	}
 
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Successfully killed process %s (PID: %d)", name, pid))
-	h.api.Send(msg)
+	msg := transport.NewMessage(chatID, fmt.Sprintf("Successfully killed process %s (PID: %d)", name, pid))
+	h.messenger.Send(msg)
+}
+
+func sortProcesses(processes []ProcessInfo, by string) {
+	sort.Slice(processes, func(i, j int) bool {
+		switch by {
+		case "cpu":
+			return processes[i].CPU > processes[j].CPU
+		case "name":
+			return strings.ToLower(processes[i].Name) < strings.ToLower(processes[j].Name)
+		case "pid":
+			return processes[i].PID < processes[j].PID
+		default:
+			return processes[i].Memory > processes[j].Memory
+		}
+	})
 }
 
 func (h *ProcessHandler) getUserProcesses() ([]ProcessInfo, error) {
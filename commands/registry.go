@@ -0,0 +1,216 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"remoteadmin/config"
+	"remoteadmin/transport"
+	"strings"
+	"sync"
+)
+
+type Request struct {
+	Context  context.Context
+	ChatID   int64
+	UserID   int64
+	UserName string
+	Command  string
+	Args     string
+	Text     string
+
+	CallbackID string
+	MessageID  int
+
+	// Via names the channel for requests that did not come from Telegram,
+	// such as "api". Their ChatID only routes replies back to the caller.
+	Via string
+}
+
+func (r *Request) IsCallback() bool {
+	return r.CallbackID != ""
+}
+
+// Failed is a command failure the handler has already explained in the
+// chat, so the dispatcher records it without replying again.
+type Failed struct {
+	Reason string
+}
+
+func (e *Failed) Error() string {
+	return e.Reason
+}
+
+// fail sends text to the chat and returns it as the command's error.
+func fail(messenger transport.Messenger, chatID int64, text string) error {
+	messenger.Send(transport.NewMessage(chatID, text))
+	return &Failed{Reason: text}
+}
+
+type ExecMode int
+
+const (
+	ExecQueued ExecMode = iota
+	ExecHeavy
+	ExecInline
+)
+
+type CommandInfo struct {
+	Name        string
+	Aliases     []string
+	Usage       string
+	Description string
+	Category    string
+	Role        config.Role
+	Exec        ExecMode
+	Hidden      bool
+	// SecretArgs keeps the arguments, such as two-factor codes, out of
+	// the audit log and diagnostics.
+	SecretArgs bool
+	// RawArgs commands read their arguments as typed text rather than
+	// as separate arguments.
+	RawArgs bool
+}
+
+type Command interface {
+	Info() CommandInfo
+	Handle(req *Request) error
+}
+
+type CallbackHandler interface {
+	HandleCallback(req *Request) error
+}
+
+type commandFunc struct {
+	info     CommandInfo
+	run      func(req *Request) error
+	callback func(req *Request) error
+}
+
+func NewCommand(info CommandInfo, run func(req *Request) error) Command {
+	return &commandFunc{info: info, run: run}
+}
+
+type interactiveCommand struct {
+	commandFunc
+}
+
+func NewInteractiveCommand(info CommandInfo, run, callback func(req *Request) error) Command {
+	return &interactiveCommand{commandFunc{info: info, run: run, callback: callback}}
+}
+
+func (c *interactiveCommand) HandleCallback(req *Request) error {
+	return c.callback(req)
+}
+
+func (c *commandFunc) Info() CommandInfo {
+	return c.info
+}
+
+func (c *commandFunc) Handle(req *Request) error {
+	return c.run(req)
+}
+
+type Registry struct {
+	mu       sync.RWMutex
+	commands []Command
+	index    map[string]Command
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		index: make(map[string]Command),
+	}
+}
+
+func (r *Registry) Register(cmd Command) error {
+	info := cmd.Info()
+	names := append([]string{info.Name}, info.Aliases...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range names {
+		key := strings.ToLower(name)
+		if key == "" {
+			return fmt.Errorf("command %q has an empty name or alias", info.Name)
+		}
+		if _, exists := r.index[key]; exists {
+			return fmt.Errorf("command name %q is already registered", name)
+		}
+	}
+
+	for _, name := range names {
+		r.index[strings.ToLower(name)] = cmd
+	}
+	r.commands = append(r.commands, cmd)
+
+	return nil
+}
+
+func (r *Registry) MustRegister(cmds ...Command) {
+	for _, cmd := range cmds {
+		if err := r.Register(cmd); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *Registry) Lookup(name string) (Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cmd, ok := r.index[strings.ToLower(name)]
+	return cmd, ok
+}
+
+func (r *Registry) Commands() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cmds := make([]Command, len(r.commands))
+	copy(cmds, r.commands)
+	return cmds
+}
+
+// ParseCommand splits "/name@bot args". Commands addressed to a different
+// bot are not ours and come back with ok false; mentioned reports whether
+// the command named this bot explicitly.
+func ParseCommand(text, botName string) (name string, args string, mentioned bool, ok bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", "", false, false
+	}
+
+	head := text
+	if i := strings.IndexAny(text, " \t\n"); i != -1 {
+		head = text[:i]
+		args = strings.TrimSpace(text[i:])
+	}
+
+	name = strings.TrimPrefix(head, "/")
+	if at := strings.Index(name, "@"); at != -1 {
+		if !strings.EqualFold(name[at+1:], botName) {
+			return "", "", false, false
+		}
+		name = name[:at]
+		mentioned = true
+	}
+
+	if name == "" {
+		return "", "", false, false
+	}
+
+	return strings.ToLower(name), args, mentioned, true
+}
+
+func CallbackData(command string, payload string) string {
+	return command + ":" + payload
+}
+
+func ParseCallbackData(data string) (command string, payload string, ok bool) {
+	command, payload, ok = strings.Cut(data, ":")
+	if !ok || command == "" {
+		return "", "", false
+	}
+	return strings.ToLower(command), payload, true
+}
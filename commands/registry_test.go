@@ -0,0 +1,61 @@
+package commands
+
+import "testing"
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text          string
+		wantName      string
+		wantArgs      string
+		wantMentioned bool
+		wantOK        bool
+	}{
+		{"/info", "info", "", false, true},
+		{"  /SS  2 ", "ss", "2", false, true},
+		{"/kill\n1234", "kill", "1234", false, true},
+		{"/info@AdminBot", "info", "", true, true},
+		{"/info@adminbot --all", "info", "--all", true, true},
+		{"/info@OtherBot", "", "", false, false},
+		{"info", "", "", false, false},
+		{"/", "", "", false, false},
+		{"/@AdminBot", "", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			name, args, mentioned, ok := ParseCommand(tt.text, "AdminBot")
+			if name != tt.wantName || args != tt.wantArgs || mentioned != tt.wantMentioned || ok != tt.wantOK {
+				t.Errorf("got %q %q %v %v, want %q %q %v %v",
+					name, args, mentioned, ok, tt.wantName, tt.wantArgs, tt.wantMentioned, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	noop := func(req *Request) error { return nil }
+	r := NewRegistry()
+	r.MustRegister(NewCommand(CommandInfo{Name: "processes", Aliases: []string{"ps"}}, noop))
+
+	if cmd, ok := r.Lookup("PS"); !ok || cmd.Info().Name != "processes" {
+		t.Error("alias lookup is case-sensitive or missing")
+	}
+
+	tests := []struct {
+		name string
+		info CommandInfo
+	}{
+		{"name taken", CommandInfo{Name: "Processes"}},
+		{"alias taken", CommandInfo{Name: "top", Aliases: []string{"ps"}}},
+		{"empty alias", CommandInfo{Name: "top", Aliases: []string{""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.Register(NewCommand(tt.info, noop)); err == nil {
+				t.Error("registered")
+			}
+		})
+	}
+	if _, ok := r.Lookup("top"); ok || len(r.Commands()) != 1 {
+		t.Error("a rejected command was partly registered")
+	}
+}
@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"fmt"
+	"remoteadmin/argparse"
+	"remoteadmin/config"
+	"remoteadmin/format"
+	"remoteadmin/schedule"
+	"remoteadmin/transport"
+	"strconv"
+	"strings"
+)
+
+const scheduleExample = "Example: /schedule add \"0 9 * * 1-5\" /info"
+
+type ScheduleHandler struct {
+	messenger transport.Messenger
+	scheduler *schedule.Scheduler
+	config    *config.Config
+	check     func(userID, chatID int64, line string) error
+}
+
+// check reports why userID may not run the command line in chatID, if
+// anything stops them. It runs when a schedule is added; the bot checks
+// again before every run.
+func NewScheduleHandler(messenger transport.Messenger, scheduler *schedule.Scheduler, cfg *config.Config, check func(userID, chatID int64, line string) error) *ScheduleHandler {
+	return &ScheduleHandler{
+		messenger: messenger,
+		scheduler: scheduler,
+		config:    cfg,
+		check:     check,
+	}
+}
+
+func (h *ScheduleHandler) HandleScheduleCommand(chatID, userID int64, userName, args string) error {
+	action, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+
+	switch strings.ToLower(action) {
+	case "add":
+		return h.add(chatID, userID, userName, rest)
+	case "", "list":
+		h.list(chatID)
+		return nil
+	case "remove", "rm", "delete":
+		return h.remove(chatID, userID, rest)
+	default:
+		return fail(h.messenger, chatID, "Usage: /schedule add \"<cron>\" [--chat <chat ID>] /command [args]\n       /schedule list\n       /schedule remove <id>\n"+scheduleExample)
+	}
+}
+
+// add reads `"<cron>" [--chat <id>] /command args`. Everything from the
+// first word starting with / is the command line to run, so its own
+// options are left alone.
+func (h *ScheduleHandler) add(chatID, userID int64, userName, args string) error {
+	words, err := argparse.Split(args)
+	if err != nil {
+		return fail(h.messenger, chatID, err.Error()+"\n"+scheduleExample)
+	}
+
+	split := len(words)
+	for i, word := range words {
+		if i > 0 && strings.HasPrefix(word, "/") {
+			split = i
+			break
+		}
+	}
+	if split == len(words) {
+		return fail(h.messenger, chatID, "missing the command to run\n"+scheduleExample)
+	}
+
+	quoted := make([]string, split)
+	for i, word := range words[:split] {
+		quoted[i] = argparse.Quote(word)
+	}
+
+	flags := argparse.New("schedule add")
+	target := flags.String("chat", "", "chat to post the output to, default this chat")
+	flags.Arg("cron", true)
+	parsed, err := flags.Parse(strings.Join(quoted, " "))
+	if err != nil {
+		return fail(h.messenger, chatID, err.Error()+"\n"+scheduleExample)
+	}
+
+	postTo := chatID
+	if *target != "" {
+		postTo, err = strconv.ParseInt(*target, 10, 64)
+		if err != nil {
+			return fail(h.messenger, chatID, "Invalid chat ID. Please provide a valid number.")
+		}
+	}
+
+	line := joinCommandLine(words[split:])
+	if err := h.check(userID, postTo, line); err != nil {
+		return fail(h.messenger, chatID, fmt.Sprintf("Cannot schedule %s: %v", line, err))
+	}
+
+	sched, err := h.scheduler.Add(schedule.Schedule{
+		Spec:     parsed[0],
+		Command:  line,
+		ChatID:   postTo,
+		UserID:   userID,
+		UserName: userName,
+	})
+	if err != nil {
+		return fail(h.messenger, chatID, fmt.Sprintf("Cannot schedule %s: %v\n%s", line, err, scheduleExample))
+	}
+
+	h.reply(chatID, fmt.Sprintf("Schedule #%d added: %s runs at %q, next at %s.", sched.ID, sched.Command, sched.Spec, sched.Next().Format("2006-01-02 15:04")))
+	return nil
+}
+
+func (h *ScheduleHandler) list(chatID int64) {
+	list := h.scheduler.List()
+	if len(list) == 0 {
+		h.reply(chatID, "No schedules. "+scheduleExample)
+		return
+	}
+
+	doc := format.NewDoc()
+	doc.Line(format.Bold("Schedules")).Blank()
+
+	for _, sched := range list {
+		doc.Line(format.Textf("#%d ", sched.ID), format.Code(sched.Spec), format.Text(" "), format.Code(sched.Command))
+		doc.Line(format.Textf("   Owner: %s (%d), posts to %d", sched.UserName, sched.UserID, sched.ChatID))
+
+		next := sched.Next()
+		if next.IsZero() {
+			doc.Line(format.Text("   Next: never"))
+		} else {
+			doc.Line(format.Textf("   Next: %s", next.Format("2006-01-02 15:04")))
+		}
+		if !sched.LastRun.IsZero() {
+			doc.Line(format.Textf("   Last: %s", sched.LastRun.Format("2006-01-02 15:04")))
+		}
+	}
+
+	doc.Blank().Line(format.Text("Use /schedule remove <id> to delete one."))
+	h.messenger.Send(doc.Message(chatID))
+}
+
+// remove deletes a schedule. Only its creator or an admin may.
+func (h *ScheduleHandler) remove(chatID, userID int64, args string) error {
+	flags := argparse.New("schedule remove")
+	flags.Arg("id", true)
+	words, err := flags.Parse(args)
+	if err != nil {
+		return fail(h.messenger, chatID, err.Error()+"\nExample: /schedule remove 3")
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(words[0], "#"))
+	if err != nil {
+		return fail(h.messenger, chatID, "Invalid schedule ID. Please provide a valid number.")
+	}
+
+	sched, ok := h.scheduler.Get(id)
+	if !ok {
+		return fail(h.messenger, chatID, fmt.Sprintf("No schedule #%d", id))
+	}
+	if sched.UserID != userID && !h.config.HasRole(userID, config.RoleAdmin) {
+		return fail(h.messenger, chatID, fmt.Sprintf("Schedule #%d belongs to %s; only they or an admin can remove it.", id, sched.UserName))
+	}
+
+	h.scheduler.Remove(id)
+	h.reply(chatID, fmt.Sprintf("Schedule #%d removed (%s)", id, sched.Command))
+	return nil
+}
+
+func (h *ScheduleHandler) reply(chatID int64, text string) {
+	msg := transport.NewMessage(chatID, text)
+	h.messenger.Send(msg)
+}
+
+// joinCommandLine puts split words back into one line, quoting only the
+// ones that need it.
+func joinCommandLine(words []string) string {
+	parts := make([]string, len(words))
+	for i, word := range words {
+		if word == "" || strings.ContainsAny(word, " \t\n\r\"'\\") {
+			word = argparse.Quote(word)
+		}
+		parts[i] = word
+	}
+	return strings.Join(parts, " ")
+}
@@ -6,39 +6,80 @@ import (
 	"image/png"
 	"os"
 	"path/filepath"
+	"remoteadmin/format"
+	"remoteadmin/transport"
+	"strconv"
 	"time"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/kbinani/screenshot"
 )
 
 type ScreenshotHandler struct {
-	api *tgbotapi.BotAPI
+	messenger transport.Messenger
 }
 
-func NewScreenshotHandler(api *tgbotapi.BotAPI) *ScreenshotHandler {
+func NewScreenshotHandler(messenger transport.Messenger) *ScreenshotHandler {
 	return &ScreenshotHandler{
-		api: api,
+		messenger: messenger,
 	}
 }
 
-func (h *ScreenshotHandler) HandleScreenshotCommand(chatID int64) {
+func (h *ScreenshotHandler) HandleScreenshotCommand(chatID int64) error {
+	displays := screenshot.NumActiveDisplays()
+
+	if displays <= 1 {
+		return h.captureEachMonitor(chatID)
+	}
+
+	mainMonitorIndex := h.findMainMonitor()
+	var rows [][]transport.Button
+
+	for i := 0; i < displays; i++ {
+		bounds := screenshot.GetDisplayBounds(i)
+		label := fmt.Sprintf("Monitor %d (%dx%d)", i+1, bounds.Dx(), bounds.Dy())
+		if i == mainMonitorIndex {
+			label += " - Main"
+		}
+		rows = append(rows, transport.NewRow(
+			transport.NewButton(label, CallbackData("ss", strconv.Itoa(i))),
+		))
+	}
+	rows = append(rows, transport.NewRow(
+		transport.NewButton("Each monitor separately", CallbackData("ss", "each")),
+	))
+
+	msg := transport.NewMessage(chatID, fmt.Sprintf("%d monitors found. Which one should be captured?", displays))
+	msg.Keyboard = transport.NewKeyboard(rows...)
+	h.messenger.Send(msg)
+	return nil
+}
+
+func (h *ScreenshotHandler) HandleScreenshotCallback(chatID int64, payload string) error {
+	if payload == "each" {
+		return h.captureEachMonitor(chatID)
+	}
+
+	index, err := strconv.Atoi(payload)
+	if err != nil || index < 0 || index >= screenshot.NumActiveDisplays() {
+		return fail(h.messenger, chatID, "That monitor is no longer available")
+	}
+
+	return h.captureMonitor(chatID, index, index == h.findMainMonitor())
+}
+
+func (h *ScreenshotHandler) captureEachMonitor(chatID int64) error {
 	displays := screenshot.NumActiveDisplays()
 
 	if displays == 0 {
-		msg := tgbotapi.NewMessage(chatID, "No active displays found")
-		h.api.Send(msg)
-		return
+		return fail(h.messenger, chatID, "No active displays found")
 	}
 
-	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Capturing %d monitor(s)...", displays))
-	h.api.Send(msg)
+	msg := transport.NewMessage(chatID, fmt.Sprintf("Capturing %d monitor(s)...", displays))
+	h.messenger.Send(msg)
 
-	screenshotDir := os.TempDir()
+	screenshotDir := tempDir()
 	if err := os.MkdirAll(screenshotDir, 0755); err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to create screenshots directory")
-		h.api.Send(errorMsg)
-		return
+		return fail(h.messenger, chatID, "Failed to create screenshots directory")
 	}
 
 	successCount := 0
@@ -72,10 +113,10 @@ func (h *ScreenshotHandler) HandleScreenshotCommand(chatID int64) {
 			continue
 		}
 
-		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FilePath(filepath))
+		photo := transport.NewPhoto(chatID, filepath)
 		photo.Caption = fmt.Sprintf("Monitor %d (%dx%d)", i+1, bounds.Dx(), bounds.Dy())
 
-		_, err = h.api.Send(photo)
+		err = h.messenger.SendFile(photo)
 		if err != nil {
 			failedDisplays = append(failedDisplays, i)
 		} else {
@@ -85,29 +126,27 @@ func (h *ScreenshotHandler) HandleScreenshotCommand(chatID int64) {
 		os.Remove(filepath)
 	}
 
-	if successCount == displays {
-		summaryMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Successfully captured %d monitor(s)", successCount))
-		h.api.Send(summaryMsg)
-	} else if successCount > 0 {
-		summaryMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Captured %d of %d monitor(s). Failed: %v", successCount, displays, failedDisplays))
-		h.api.Send(summaryMsg)
-	} else {
-		summaryMsg := tgbotapi.NewMessage(chatID, "Failed to capture any screenshots")
-		h.api.Send(summaryMsg)
+	if successCount == 0 {
+		return fail(h.messenger, chatID, "Failed to capture any screenshots")
+	}
+	if successCount < displays {
+		return fail(h.messenger, chatID, fmt.Sprintf("Captured %d of %d monitor(s). Failed: %v", successCount, displays, failedDisplays))
 	}
+
+	summaryMsg := transport.NewMessage(chatID, fmt.Sprintf("Successfully captured %d monitor(s)", successCount))
+	h.messenger.Send(summaryMsg)
+	return nil
 }
 
-func (h *ScreenshotHandler) HandleScreenshotAllCommand(chatID int64) {
+func (h *ScreenshotHandler) HandleScreenshotAllCommand(chatID int64) error {
 	displays := screenshot.NumActiveDisplays()
 
 	if displays == 0 {
-		msg := tgbotapi.NewMessage(chatID, "No active displays found")
-		h.api.Send(msg)
-		return
+		return fail(h.messenger, chatID, "No active displays found")
 	}
 
-	msg := tgbotapi.NewMessage(chatID, "Capturing all monitors as single image...")
-	h.api.Send(msg)
+	msg := transport.NewMessage(chatID, "Capturing all monitors as single image...")
+	h.messenger.Send(msg)
 
 	var allBounds []image.Rectangle
 	for i := 0; i < displays; i++ {
@@ -137,16 +176,12 @@ func (h *ScreenshotHandler) HandleScreenshotAllCommand(chatID int64) {
 
 	img, err := screenshot.CaptureRect(combinedBounds)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to capture screenshot")
-		h.api.Send(errorMsg)
-		return
+		return fail(h.messenger, chatID, "Failed to capture screenshot")
 	}
 
-	screenshotDir := os.TempDir()
+	screenshotDir := tempDir()
 	if err := os.MkdirAll(screenshotDir, 0755); err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to create screenshots directory")
-		h.api.Send(errorMsg)
-		return
+		return fail(h.messenger, chatID, "Failed to create screenshots directory")
 	}
 
 	timestamp := time.Now().Format("20060102_150405")
@@ -155,112 +190,107 @@ func (h *ScreenshotHandler) HandleScreenshotAllCommand(chatID int64) {
 
 	file, err := os.Create(filepath)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to create screenshot file")
-		h.api.Send(errorMsg)
-		return
+		return fail(h.messenger, chatID, "Failed to create screenshot file")
 	}
 
 	err = png.Encode(file, img)
 	file.Close()
 
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to save screenshot")
-		h.api.Send(errorMsg)
 		os.Remove(filepath)
-		return
+		return fail(h.messenger, chatID, "Failed to save screenshot")
 	}
 
-	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FilePath(filepath))
+	photo := transport.NewPhoto(chatID, filepath)
 	photo.Caption = fmt.Sprintf("All Monitors (%dx%d) - %d display(s)",
 		combinedBounds.Dx(), combinedBounds.Dy(), displays)
 
-	_, err = h.api.Send(photo)
+	err = h.messenger.SendFile(photo)
+	os.Remove(filepath)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to send screenshot")
-		h.api.Send(errorMsg)
-	} else {
-		successMsg := tgbotapi.NewMessage(chatID, "Screenshot sent successfully")
-		h.api.Send(successMsg)
+		return fail(h.messenger, chatID, "Failed to send screenshot")
 	}
 
-	os.Remove(filepath)
+	successMsg := transport.NewMessage(chatID, "Screenshot sent successfully")
+	h.messenger.Send(successMsg)
+	return nil
 }
 
-func (h *ScreenshotHandler) HandleMainMonitorCommand(chatID int64) {
+func (h *ScreenshotHandler) HandleMainMonitorCommand(chatID int64) error {
 	displays := screenshot.NumActiveDisplays()
 
 	if displays == 0 {
-		msg := tgbotapi.NewMessage(chatID, "No active displays found")
-		h.api.Send(msg)
-		return
+		return fail(h.messenger, chatID, "No active displays found")
 	}
 
-	msg := tgbotapi.NewMessage(chatID, "Capturing main monitor...")
-	h.api.Send(msg)
+	return h.captureMonitor(chatID, h.findMainMonitor(), true)
+}
 
-	screenshotDir := os.TempDir()
+func (h *ScreenshotHandler) captureMonitor(chatID int64, index int, isMain bool) error {
+	label := fmt.Sprintf("monitor %d", index+1)
+	if isMain {
+		label = "main monitor"
+	}
+
+	msg := transport.NewMessage(chatID, fmt.Sprintf("Capturing %s...", label))
+	h.messenger.Send(msg)
+
+	screenshotDir := tempDir()
 	if err := os.MkdirAll(screenshotDir, 0755); err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to create screenshots directory")
-		h.api.Send(errorMsg)
-		return
+		return fail(h.messenger, chatID, "Failed to create screenshots directory")
 	}
 
-	mainMonitorIndex := h.findMainMonitor()
-	bounds := screenshot.GetDisplayBounds(mainMonitorIndex)
+	bounds := screenshot.GetDisplayBounds(index)
 
 	img, err := screenshot.CaptureRect(bounds)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to capture main monitor")
-		h.api.Send(errorMsg)
-		return
+		return fail(h.messenger, chatID, fmt.Sprintf("Failed to capture %s", label))
 	}
 
 	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("main_monitor_%s.png", timestamp)
+	filename := fmt.Sprintf("monitor_%d_%s.png", index+1, timestamp)
 	filepath := filepath.Join(screenshotDir, filename)
 
 	file, err := os.Create(filepath)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to create screenshot file")
-		h.api.Send(errorMsg)
-		return
+		return fail(h.messenger, chatID, "Failed to create screenshot file")
 	}
 
 	err = png.Encode(file, img)
 	file.Close()
 
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to save screenshot")
-		h.api.Send(errorMsg)
 		os.Remove(filepath)
-		return
+		return fail(h.messenger, chatID, "Failed to save screenshot")
 	}
 
-	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FilePath(filepath))
-	photo.Caption = fmt.Sprintf("Main Monitor %d (%dx%d)", mainMonitorIndex+1, bounds.Dx(), bounds.Dy())
+	photo := transport.NewPhoto(chatID, filepath)
+	photo.Caption = fmt.Sprintf("Monitor %d (%dx%d)", index+1, bounds.Dx(), bounds.Dy())
+	if isMain {
+		photo.Caption = "Main " + photo.Caption
+	}
 
-	_, err = h.api.Send(photo)
+	err = h.messenger.SendFile(photo)
+	os.Remove(filepath)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to send screenshot")
-		h.api.Send(errorMsg)
-	} else {
-		successMsg := tgbotapi.NewMessage(chatID, "Main monitor screenshot sent successfully")
-		h.api.Send(successMsg)
+		return fail(h.messenger, chatID, "Failed to send screenshot")
 	}
 
-	os.Remove(filepath)
+	successMsg := transport.NewMessage(chatID, fmt.Sprintf("Screenshot of %s sent successfully", label))
+	h.messenger.Send(successMsg)
+	return nil
 }
 
-func (h *ScreenshotHandler) GetDisplayInfo() string {
+func (h *ScreenshotHandler) GetDisplayInfo() *format.Doc {
 	displays := screenshot.NumActiveDisplays()
 
 	if displays == 0 {
-		return "No active displays found"
+		return format.NewDoc().Line(format.Text("No active displays found"))
 	}
 
 	mainMonitorIndex := h.findMainMonitor()
-	var info string
-	info += fmt.Sprintf("**Display Information** (%d monitor(s)):\n\n", displays)
+	doc := format.NewDoc()
+	doc.Line(format.Bold("Display Information"), format.Textf(" (%d monitor(s)):", displays)).Blank()
 
 	for i := 0; i < displays; i++ {
 		bounds := screenshot.GetDisplayBounds(i)
@@ -268,13 +298,14 @@ func (h *ScreenshotHandler) GetDisplayInfo() string {
 		if i == mainMonitorIndex {
 			monitorType = " (Main)"
 		}
-		info += fmt.Sprintf("**Monitor %d%s:**\n", i+1, monitorType)
-		info += fmt.Sprintf("• Resolution: %dx%d\n", bounds.Dx(), bounds.Dy())
-		info += fmt.Sprintf("• Position: (%d, %d)\n", bounds.Min.X, bounds.Min.Y)
-		info += fmt.Sprintf("• Area: %dx%d pixels\n\n", bounds.Max.X-bounds.Min.X, bounds.Max.Y-bounds.Min.Y)
+		doc.Line(format.Bold(fmt.Sprintf("Monitor %d%s:", i+1, monitorType)))
+		doc.Field("Resolution", fmt.Sprintf("%dx%d", bounds.Dx(), bounds.Dy()))
+		doc.Field("Position", fmt.Sprintf("(%d, %d)", bounds.Min.X, bounds.Min.Y))
+		doc.Field("Area", fmt.Sprintf("%dx%d pixels", bounds.Max.X-bounds.Min.X, bounds.Max.Y-bounds.Min.Y))
+		doc.Blank()
 	}
 
-	return info
+	return doc
 }
 
 func (h *ScreenshotHandler) findMainMonitor() int {
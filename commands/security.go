@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+	"remoteadmin/argparse"
+	"remoteadmin/guard"
+	"remoteadmin/transport"
+	"strconv"
+	"strings"
+)
+
+type SecurityHandler struct {
+	messenger transport.Messenger
+	guard     *guard.Guard
+}
+
+func NewSecurityHandler(messenger transport.Messenger, guard *guard.Guard) *SecurityHandler {
+	return &SecurityHandler{
+		messenger: messenger,
+		guard:     guard,
+	}
+}
+
+func (h *SecurityHandler) HandleBlockedCommand(chatID int64) {
+	blocked := h.guard.Blocked()
+	if len(blocked) == 0 {
+		msg := transport.NewMessage(chatID, "No users are being ignored")
+		h.messenger.Send(msg)
+		return
+	}
+
+	var message strings.Builder
+	message.WriteString("Ignored Users:\n\n")
+
+	for i, user := range blocked {
+		until := "permanently"
+		if !user.Permanent() {
+			until = "until " + user.Until.Format("2006-01-02 15:04")
+		}
+		message.WriteString(fmt.Sprintf("%d. %d (@%s) - %s\n", i+1, user.UserID, user.UserName, until))
+	}
+
+	msg := transport.NewMessage(chatID, message.String())
+	h.messenger.Send(msg)
+}
+
+func (h *SecurityHandler) HandleUnblockCommand(chatID int64, args string) error {
+	flags := argparse.New("unblock")
+	flags.Arg("user ID", true)
+	words, err := flags.Parse(args)
+	if err != nil {
+		return fail(h.messenger, chatID, err.Error())
+	}
+
+	userID, err := strconv.ParseInt(words[0], 10, 64)
+	if err != nil {
+		return fail(h.messenger, chatID, "Invalid user ID. Please provide a valid number.")
+	}
+
+	if !h.guard.Unblock(userID) {
+		return fail(h.messenger, chatID, fmt.Sprintf("User %d is not being ignored", userID))
+	}
+
+	msg := transport.NewMessage(chatID, fmt.Sprintf("User %d is no longer ignored", userID))
+	h.messenger.Send(msg)
+	return nil
+}
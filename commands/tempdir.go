@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	tempDirOnce sync.Once
+	tempDirPath string
+)
+
+// tempDir returns a per-process directory for screenshots, recordings and
+// downloads so everything can be removed in one go on shutdown.
+func tempDir() string {
+	tempDirOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "remoteadmin-")
+		if err != nil {
+			dir = os.TempDir()
+		}
+		tempDirPath = dir
+	})
+	return tempDirPath
+}
+
+func RemoveTempFiles() error {
+	if tempDirPath == "" || tempDirPath == os.TempDir() {
+		return nil
+	}
+	return os.RemoveAll(tempDirPath)
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"remoteadmin/argparse"
+	"remoteadmin/totp"
+	"remoteadmin/transport"
+)
+
+type TwoFactorHandler struct {
+	messenger  transport.Messenger
+	auth       *totp.Authenticator
+	issuer     string
+	onVerified func(userID int64)
+}
+
+// onVerified runs after a plain "/totp <code>" succeeds, so the caller can
+// resume whatever command was waiting for the code.
+func NewTwoFactorHandler(messenger transport.Messenger, auth *totp.Authenticator, issuer string, onVerified func(userID int64)) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		messenger:  messenger,
+		auth:       auth,
+		issuer:     issuer,
+		onVerified: onVerified,
+	}
+}
+
+func (h *TwoFactorHandler) HandleTOTPCommand(chatID, userID int64, userName, args string) error {
+	flags := argparse.New("totp")
+	flags.Arg("enroll|confirm|disable|status|code", false)
+	flags.Arg("code", false)
+	parts, err := flags.Parse(args)
+	if err != nil {
+		return fail(h.messenger, chatID, err.Error())
+	}
+	if len(parts) == 0 {
+		h.showStatus(chatID, userID)
+		return nil
+	}
+
+	switch parts[0] {
+	case "status":
+		h.showStatus(chatID, userID)
+		return nil
+	case "enroll":
+		return h.enroll(chatID, userID, userName)
+	case "confirm":
+		if len(parts) < 2 {
+			return fail(h.messenger, chatID, "Usage: /totp confirm <code>")
+		}
+		return h.confirm(chatID, userID, parts[1])
+	case "disable":
+		if len(parts) < 2 {
+			return fail(h.messenger, chatID, "Usage: /totp disable <code>")
+		}
+		return h.disable(chatID, userID, parts[1])
+	default:
+		return h.verify(chatID, userID, parts[0])
+	}
+}
+
+func (h *TwoFactorHandler) showStatus(chatID, userID int64) {
+	if !h.auth.Enrolled(userID) {
+		h.reply(chatID, "Two-factor authentication: off\n\n"+
+			"/totp enroll - Set up an authenticator app")
+		return
+	}
+
+	h.reply(chatID, "Two-factor authentication: on\n\n"+
+		"/totp <code> - Unlock sensitive commands\n"+
+		"/totp disable <code> - Turn it off")
+}
+
+func (h *TwoFactorHandler) enroll(chatID, userID int64, userName string) error {
+	secret, err := h.auth.BeginEnrollment(userID)
+	if err != nil {
+		return fail(h.messenger, chatID, h.describe(err))
+	}
+
+	account := userName
+	if account == "" {
+		account = fmt.Sprint(userID)
+	}
+
+	h.reply(chatID, fmt.Sprintf("Add this key to your authenticator app:\n\n%s\n\nor open:\n%s\n\n"+
+		"Then send /totp confirm <code> to turn it on.",
+		secret, totp.URI(secret, account, h.issuer)))
+	return nil
+}
+
+func (h *TwoFactorHandler) confirm(chatID, userID int64, code string) error {
+	if err := h.auth.ConfirmEnrollment(userID, code); err != nil {
+		return fail(h.messenger, chatID, h.describe(err))
+	}
+
+	h.reply(chatID, "Two-factor authentication is on. Sensitive commands will ask for a code.")
+	return nil
+}
+
+func (h *TwoFactorHandler) disable(chatID, userID int64, code string) error {
+	if err := h.auth.Disable(userID, code); err != nil {
+		return fail(h.messenger, chatID, h.describe(err))
+	}
+
+	h.reply(chatID, "Two-factor authentication is off.")
+	return nil
+}
+
+func (h *TwoFactorHandler) verify(chatID, userID int64, code string) error {
+	if err := h.auth.Verify(userID, code); err != nil {
+		return fail(h.messenger, chatID, h.describe(err))
+	}
+
+	h.reply(chatID, "Code accepted.")
+	if h.onVerified != nil {
+		h.onVerified(userID)
+	}
+	return nil
+}
+
+func (h *TwoFactorHandler) describe(err error) string {
+	switch {
+	case errors.Is(err, totp.ErrInvalidCode):
+		return "Invalid or already used code."
+	case errors.Is(err, totp.ErrLockedOut):
+		return "Too many wrong codes. Try again later."
+	case errors.Is(err, totp.ErrNotEnrolled):
+		return "Two-factor authentication is not enabled. Use /totp enroll first."
+	case errors.Is(err, totp.ErrAlreadyEnrolled):
+		return "Two-factor authentication is already enabled."
+	case errors.Is(err, totp.ErrNoEnrollment):
+		return "No enrollment in progress. Use /totp enroll first."
+	default:
+		return fmt.Sprintf("Failed to save two-factor settings: %v", err)
+	}
+}
+
+func (h *TwoFactorHandler) reply(chatID int64, text string) {
+	msg := transport.NewMessage(chatID, text)
+	h.messenger.Send(msg)
+}
@@ -1,109 +1,126 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"remoteadmin/argparse"
+	"remoteadmin/transport"
 	"runtime"
 	"time"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/kbinani/screenshot"
 )
 
 type VideoHandler struct {
-	api               *tgbotapi.BotAPI
+	messenger         transport.Messenger
 	screenshotHandler *ScreenshotHandler
 }
 
-func NewVideoHandler(api *tgbotapi.BotAPI) *VideoHandler {
+func NewVideoHandler(messenger transport.Messenger) *VideoHandler {
 	return &VideoHandler{
-		api:               api,
-		screenshotHandler: NewScreenshotHandler(api),
+		messenger:         messenger,
+		screenshotHandler: NewScreenshotHandler(messenger),
 	}
 }
 
-func (h *VideoHandler) HandleVideoCommand(chatID int64) {
+func (h *VideoHandler) HandleVideoCommand(ctx context.Context, chatID int64, args string) error {
+	flags := argparse.New("vid")
+	duration := flags.Duration("duration", 5*time.Second, time.Second, time.Minute, "Recording length")
+	monitor := flags.Int("monitor", 0, 0, 16, "Monitor number, 0 for the main one")
+	if _, err := flags.Parse(args); err != nil {
+		return fail(h.messenger, chatID, err.Error())
+	}
+
 	displays := screenshot.NumActiveDisplays()
 	if displays == 0 {
-		msg := tgbotapi.NewMessage(chatID, "No active displays found")
-		h.api.Send(msg)
-		return
+		return fail(h.messenger, chatID, "No active displays found")
+	}
+
+	index := h.screenshotHandler.findMainMonitor()
+	if *monitor > 0 {
+		if *monitor > displays {
+			return fail(h.messenger, chatID, fmt.Sprintf("Monitor %d not found. There are %d monitor(s).", *monitor, displays))
+		}
+		index = *monitor - 1
 	}
 
 	if !h.isFFmpegAvailable() {
-		msg := tgbotapi.NewMessage(chatID, "FFmpeg not found. Taking screenshot instead...")
-		h.api.Send(msg)
+		msg := transport.NewMessage(chatID, "FFmpeg not found. Taking screenshot instead...")
+		h.messenger.Send(msg)
 
-		h.screenshotHandler.HandleMainMonitorCommand(chatID)
-		return
+		return h.screenshotHandler.HandleMainMonitorCommand(chatID)
 	}
 
-	msg := tgbotapi.NewMessage(chatID, "Starting video recording (5 seconds)...")
-	h.api.Send(msg)
+	msg := transport.NewMessage(chatID, fmt.Sprintf("Starting video recording (%d seconds)...", int((*duration).Seconds())))
+	h.messenger.Send(msg)
+
+	// ffmpeg can leave a partial file behind when it fails or is
+	// cancelled, so every output goes once the reply is done.
+	var outputs []string
+	defer func() {
+		for _, path := range outputs {
+			os.Remove(path)
+		}
+	}()
 
-	videoPath, err := h.recordVideo(5)
+	videoPath, err := h.recordVideo(ctx, *duration, index)
+	if videoPath != "" {
+		outputs = append(outputs, videoPath)
+	}
+	if ctx.Err() != nil {
+		msg := transport.NewMessage(chatID, "Video recording cancelled")
+		h.messenger.Send(msg)
+		return nil
+	}
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Failed to record video: %v", err))
-		h.api.Send(errorMsg)
-		return
+		return fail(h.messenger, chatID, fmt.Sprintf("Failed to record video: %v", err))
 	}
 
 	fileInfo, err := os.Stat(videoPath)
 	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, "Failed to get video file info")
-		h.api.Send(errorMsg)
-		os.Remove(videoPath)
-		return
+		return fail(h.messenger, chatID, "Failed to get video file info")
 	}
 
 	fileSizeMB := float64(fileInfo.Size()) / (1024 * 1024)
 
 	if fileSizeMB > 50 {
-		compressedPath, err := h.compressVideo(videoPath)
+		compressedPath, err := h.compressVideo(ctx, videoPath)
+		outputs = append(outputs, compressedPath)
+		if ctx.Err() != nil {
+			msg := transport.NewMessage(chatID, "Video compression cancelled")
+			h.messenger.Send(msg)
+			return nil
+		}
 		if err != nil {
-			errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Video too large (%.1fMB) and compression failed: %v", fileSizeMB, err))
-			h.api.Send(errorMsg)
-			os.Remove(videoPath)
-			return
+			return fail(h.messenger, chatID, fmt.Sprintf("Video too large (%.1fMB) and compression failed: %v", fileSizeMB, err))
 		}
 
 		compressedInfo, err := os.Stat(compressedPath)
 		if err != nil {
-			errorMsg := tgbotapi.NewMessage(chatID, "Failed to get compressed video info")
-			h.api.Send(errorMsg)
-			os.Remove(videoPath)
-			os.Remove(compressedPath)
-			return
+			return fail(h.messenger, chatID, "Failed to get compressed video info")
 		}
 
 		compressedSizeMB := float64(compressedInfo.Size()) / (1024 * 1024)
 		if compressedSizeMB > 50 {
-			errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Video still too large after compression (%.1fMB). Try recording for a shorter duration.", compressedSizeMB))
-			h.api.Send(errorMsg)
-			os.Remove(videoPath)
-			os.Remove(compressedPath)
-			return
+			return fail(h.messenger, chatID, fmt.Sprintf("Video still too large after compression (%.1fMB). Try recording for a shorter duration.", compressedSizeMB))
 		}
 
-		os.Remove(videoPath)
 		videoPath = compressedPath
 		fileSizeMB = compressedSizeMB
 	}
 
-	video := tgbotapi.NewVideo(chatID, tgbotapi.FilePath(videoPath))
+	video := transport.NewVideo(chatID, videoPath)
 	video.Caption = fmt.Sprintf("Screen Recording (%.1fMB)", fileSizeMB)
-	_, err = h.api.Send(video)
-	if err != nil {
-		errorMsg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Failed to send video: %v", err))
-		h.api.Send(errorMsg)
-	} else {
-		successMsg := tgbotapi.NewMessage(chatID, "Video sent successfully")
-		h.api.Send(successMsg)
+	if err := h.messenger.SendFile(video); err != nil {
+		return fail(h.messenger, chatID, fmt.Sprintf("Failed to send video: %v", err))
 	}
 
-	os.Remove(videoPath)
+	successMsg := transport.NewMessage(chatID, "Video sent successfully")
+	h.messenger.Send(successMsg)
+	return nil
 }
 
 func (h *VideoHandler) isFFmpegAvailable() bool {
@@ -111,8 +128,8 @@ func (h *VideoHandler) isFFmpegAvailable() bool {
 	return err == nil
 }
 
-func (h *VideoHandler) recordVideo(duration int) (string, error) {
-	videoDir := os.TempDir()
+func (h *VideoHandler) recordVideo(ctx context.Context, duration time.Duration, index int) (string, error) {
+	videoDir := tempDir()
 	if err := os.MkdirAll(videoDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create temp directory: %v", err)
 	}
@@ -126,15 +143,15 @@ func (h *VideoHandler) recordVideo(duration int) (string, error) {
 		return "", fmt.Errorf("no active displays found")
 	}
 
-	mainMonitorIndex := h.screenshotHandler.findMainMonitor()
-	bounds := screenshot.GetDisplayBounds(mainMonitorIndex)
+	bounds := screenshot.GetDisplayBounds(index)
+	seconds := fmt.Sprintf("%.0f", duration.Seconds())
 
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
-		cmd = exec.Command("ffmpeg",
+		cmd = exec.CommandContext(ctx, "ffmpeg",
 			"-f", "gdigrab",
 			"-framerate", "15",
-			"-t", fmt.Sprintf("%d", duration),
+			"-t", seconds,
 			"-offset_x", fmt.Sprintf("%d", bounds.Min.X),
 			"-offset_y", fmt.Sprintf("%d", bounds.Min.Y),
 			"-video_size", fmt.Sprintf("%dx%d", bounds.Dx(), bounds.Dy()),
@@ -147,12 +164,12 @@ func (h *VideoHandler) recordVideo(duration int) (string, error) {
 			videoPath,
 		)
 	} else {
-		cmd = exec.Command("ffmpeg",
+		cmd = exec.CommandContext(ctx, "ffmpeg",
 			"-f", "x11grab",
 			"-framerate", "15",
-			"-t", fmt.Sprintf("%d", duration),
+			"-t", seconds,
 			"-s", fmt.Sprintf("%dx%d", bounds.Dx(), bounds.Dy()),
-			"-i", ":0.0",
+			"-i", fmt.Sprintf(":0.0+%d,%d", bounds.Min.X, bounds.Min.Y),
 			"-c:v", "libx264",
 			"-preset", "fast",
 			"-crf", "28",
@@ -161,21 +178,21 @@ func (h *VideoHandler) recordVideo(duration int) (string, error) {
 		)
 	}
 
-	err := cmd.Run()
+	err := runFFmpeg(ctx, cmd, "video_record")
 	if err != nil {
-		return "", fmt.Errorf("ffmpeg execution failed: %v", err)
+		return videoPath, fmt.Errorf("ffmpeg execution failed: %v", err)
 	}
 
 	return videoPath, nil
 }
 
-func (h *VideoHandler) compressVideo(inputPath string) (string, error) {
+func (h *VideoHandler) compressVideo(ctx context.Context, inputPath string) (string, error) {
 	dir := filepath.Dir(inputPath)
 	ext := filepath.Ext(inputPath)
 	name := filepath.Base(inputPath[:len(inputPath)-len(ext)])
 	compressedPath := filepath.Join(dir, name+"_compressed"+ext)
 
-	cmd := exec.Command("ffmpeg",
+	cmd := exec.CommandContext(ctx, "ffmpeg",
 		"-i", inputPath,
 		"-c:v", "libx264",
 		"-preset", "slow",
@@ -190,9 +207,9 @@ func (h *VideoHandler) compressVideo(inputPath string) (string, error) {
 		compressedPath,
 	)
 
-	err := cmd.Run()
+	err := runFFmpeg(ctx, cmd, "video_compress")
 	if err != nil {
-		return "", fmt.Errorf("video compression failed: %v", err)
+		return compressedPath, fmt.Errorf("video compression failed: %v", err)
 	}
 
 	return compressedPath, nil
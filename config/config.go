@@ -2,17 +2,280 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+var roleLevels = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+func (r Role) Valid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Includes reports whether r grants everything other grants.
+func (r Role) Includes(other Role) bool {
+	return roleLevels[r] >= roleLevels[other]
+}
+
+// Chat is a group or channel the bot may answer in. Commands lists what can
+// be used there; without it the top-level group_commands applies.
+type Chat struct {
+	ID       int64    `json:"id"`
+	Name     string   `json:"name"`
+	Commands []string `json:"commands"`
+}
+
+// AllCommands in a command list allows every command.
+const AllCommands = "*"
+
+type User struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+}
+
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+const (
+	AlertAll   = "all"
+	AlertFirst = "first"
+	AlertNone  = "none"
+)
+
+const (
+	DefaultWorkerCount  = 4
+	DefaultMaxHeavyJobs = 1
+
+	DefaultConfirmTimeoutSeconds  = 60
+	DefaultShutdownTimeoutSeconds = 10
 )
 
 type Config struct {
-	BotToken        string  `json:"bot_token"`
-	AuthorizedUsers []int64 `json:"authorized_users"`
+	BotToken        string          `json:"bot_token"`
+	AuthorizedUsers []int64         `json:"authorized_users"`
+	Users           []User          `json:"users"`
+	DefaultRole     Role            `json:"default_role"`
+	CommandRoles    map[string]Role `json:"command_roles"`
+	Chats           []Chat          `json:"chats"`
+	GroupCommands   []string        `json:"group_commands"`
+	WorkerCount     int             `json:"worker_count"`
+	MaxHeavyJobs    int             `json:"max_heavy_jobs"`
+
+	ConfirmTimeoutSeconds  int `json:"confirm_timeout_seconds"`
+	ShutdownTimeoutSeconds int `json:"shutdown_timeout_seconds"`
+
+	Mode    string        `json:"mode"`
+	Webhook WebhookConfig `json:"webhook"`
+
+	SendLimits SendLimitsConfig `json:"send_limits"`
+
+	Unauthorized UnauthorizedConfig `json:"unauthorized"`
+
+	TwoFactor TwoFactorConfig `json:"two_factor"`
+
+	Audit AuditConfig `json:"audit"`
+
+	Log LogConfig `json:"log"`
+
+	Metrics MetricsConfig `json:"metrics"`
+
+	API APIConfig `json:"api"`
+
+	Dashboard DashboardConfig `json:"dashboard"`
+
+	Aliases map[string]string `json:"aliases"`
+	Macros  map[string]Macro  `json:"macros"`
+
+	Schedules SchedulesConfig `json:"schedules"`
+
+	Fleet FleetConfig `json:"fleet"`
+
+	Outbox OutboxConfig `json:"outbox"`
+}
+
+// OutboxConfig says where notifications wait while they can't be sent and
+// how long they are worth delivering late.
+type OutboxConfig struct {
+	File        string `json:"file"`
+	MaxAgeHours int    `json:"max_age_hours"`
+}
+
+const (
+	FleetCoordinator = "coordinator"
+	FleetAgent       = "agent"
+)
+
+// FleetConfig lets several machines share one bot token. The coordinator
+// owns the Telegram connection and listens on ListenAddr with TLS; agents
+// dial CoordinatorAddr over TLS and prove they share Secret. Name defaults
+// to the hostname.
+type FleetConfig struct {
+	Role            string `json:"role"`
+	Name            string `json:"name"`
+	Secret          string `json:"secret"`
+	ListenAddr      string `json:"listen_addr"`
+	CertFile        string `json:"cert_file"`
+	KeyFile         string `json:"key_file"`
+	CoordinatorAddr string `json:"coordinator_addr"`
+	TLS             bool   `json:"tls"`
+	CAFile          string `json:"ca_file"`
+}
+
+// MinFleetSecretLength keeps the fleet secret long enough not to be guessed.
+const MinFleetSecretLength = 24
+
+const (
+	MissedRunsSkip    = "skip"
+	MissedRunsCatchUp = "catch_up"
+)
+
+// SchedulesConfig says where /schedule keeps its schedules and what to do
+// about runs that fell due while the bot was down: skip them, or run each
+// schedule once on startup.
+type SchedulesConfig struct {
+	File       string `json:"file"`
+	MissedRuns string `json:"missed_runs"`
+}
+
+// Macro runs its steps in order as the user who called it. Steps are
+// command lines such as "/kill {pid}"; each {param} is filled from the
+// macro's arguments in the order Params lists them.
+type Macro struct {
+	Description string   `json:"description"`
+	Params      []string `json:"params"`
+	Steps       []string `json:"steps"`
+}
+
+// DashboardConfig configures the local web dashboard. Each login acts as
+// the authorized user it names, with that user's role.
+type DashboardConfig struct {
+	Enabled        bool             `json:"enabled"`
+	ListenAddr     string           `json:"listen_addr"`
+	CertFile       string           `json:"cert_file"`
+	KeyFile        string           `json:"key_file"`
+	SessionMinutes int              `json:"session_minutes"`
+	Logins         []DashboardLogin `json:"logins"`
+}
+
+type DashboardLogin struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	UserID   int64  `json:"user_id"`
+}
+
+// MinDashboardPasswordLength keeps dashboard passwords from being trivial.
+const MinDashboardPasswordLength = 12
+
+// APIConfig configures the local REST API. Each token acts as the
+// authorized user it names, with that user's role.
+type APIConfig struct {
+	Enabled        bool       `json:"enabled"`
+	ListenAddr     string     `json:"listen_addr"`
+	CertFile       string     `json:"cert_file"`
+	KeyFile        string     `json:"key_file"`
+	TimeoutSeconds int        `json:"timeout_seconds"`
+	Tokens         []APIToken `json:"tokens"`
+}
+
+type APIToken struct {
+	Name   string `json:"name"`
+	Token  string `json:"token"`
+	UserID int64  `json:"user_id"`
+}
+
+// MinAPITokenLength keeps API tokens long enough not to be guessed.
+const MinAPITokenLength = 24
+
+type MetricsConfig struct {
+	Enabled    bool   `json:"enabled"`
+	ListenAddr string `json:"listen_addr"`
+}
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+
+	// LogToStderr as the log file writes to stderr instead of a file.
+	LogToStderr = "stderr"
+)
+
+type LogConfig struct {
+	Level  string `json:"level"`
+	Format string `json:"format"`
+	File   string `json:"file"`
+}
+
+type AuditConfig struct {
+	Path      string `json:"path"`
+	MaxSizeMB int    `json:"max_size_mb"`
+	MaxFiles  int    `json:"max_files"`
+}
+
+// TwoFactorConfig holds TOTP settings. Secrets are keyed by user ID and are
+// written back to secrets.json when a user enrolls or disables TOTP.
+type TwoFactorConfig struct {
+	SensitiveCommands []string         `json:"sensitive_commands"`
+	GraceMinutes      int              `json:"grace_minutes"`
+	MaxAttempts       int              `json:"max_attempts"`
+	LockoutMinutes    int              `json:"lockout_minutes"`
+	Secrets           map[int64]string `json:"secrets"`
+}
+
+type UnauthorizedConfig struct {
+	Alert            string `json:"alert"`
+	Silent           bool   `json:"silent"`
+	MaxAttempts      int    `json:"max_attempts"`
+	WindowMinutes    int    `json:"window_minutes"`
+	LockoutMinutes   int    `json:"lockout_minutes"`
+	BanAfterLockouts int    `json:"ban_after_lockouts"`
 }
 
+type SendLimitsConfig struct {
+	ChatIntervalMs  int `json:"chat_interval_ms"`
+	GlobalPerSecond int `json:"global_per_second"`
+	MaxRetries      int `json:"max_retries"`
+}
+
+type WebhookConfig struct {
+	URL               string `json:"url"`
+	ListenAddr        string `json:"listen_addr"`
+	CertFile          string `json:"cert_file"`
+	KeyFile           string `json:"key_file"`
+	SecretToken       string `json:"secret_token"`
+	UploadCertificate bool   `json:"upload_certificate"`
+}
+
+const configFile = "secrets.json"
+
+var defaultSensitiveCommands = []string{"kill", "vid", "audio", "file"}
+
+var defaultGroupCommands = []string{"info", "displays", "processes", "jobs", "help", "start"}
+
 func LoadConfig() (*Config, error) {
-	data, err := ioutil.ReadFile("secrets.json")
+	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
 		return nil, err
 	}
@@ -23,26 +286,560 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
+	config.applyDefaults()
+
 	return &config, nil
 }
 
+func (c *Config) applyDefaults() {
+	if c.WorkerCount <= 0 {
+		c.WorkerCount = DefaultWorkerCount
+	}
+	if c.MaxHeavyJobs <= 0 {
+		c.MaxHeavyJobs = DefaultMaxHeavyJobs
+	}
+	if c.ConfirmTimeoutSeconds <= 0 {
+		c.ConfirmTimeoutSeconds = DefaultConfirmTimeoutSeconds
+	}
+	if c.ShutdownTimeoutSeconds <= 0 {
+		c.ShutdownTimeoutSeconds = DefaultShutdownTimeoutSeconds
+	}
+	if c.DefaultRole == "" {
+		c.DefaultRole = RoleAdmin
+	}
+	if c.Mode == "" {
+		c.Mode = ModePolling
+	}
+	if c.Webhook.ListenAddr == "" {
+		c.Webhook.ListenAddr = ":8443"
+	}
+	if c.SendLimits.ChatIntervalMs <= 0 {
+		c.SendLimits.ChatIntervalMs = 1000
+	}
+	if c.SendLimits.GlobalPerSecond <= 0 {
+		c.SendLimits.GlobalPerSecond = 30
+	}
+	if c.SendLimits.MaxRetries <= 0 {
+		c.SendLimits.MaxRetries = 5
+	}
+	if c.Unauthorized.Alert == "" {
+		c.Unauthorized.Alert = AlertFirst
+	}
+	if c.Unauthorized.MaxAttempts <= 0 {
+		c.Unauthorized.MaxAttempts = 3
+	}
+	if c.Unauthorized.WindowMinutes <= 0 {
+		c.Unauthorized.WindowMinutes = 10
+	}
+	if c.Unauthorized.LockoutMinutes <= 0 {
+		c.Unauthorized.LockoutMinutes = 60
+	}
+	if c.GroupCommands == nil {
+		c.GroupCommands = defaultGroupCommands
+	}
+	if c.TwoFactor.SensitiveCommands == nil {
+		c.TwoFactor.SensitiveCommands = defaultSensitiveCommands
+	}
+	if c.TwoFactor.GraceMinutes <= 0 {
+		c.TwoFactor.GraceMinutes = 5
+	}
+	if c.TwoFactor.MaxAttempts <= 0 {
+		c.TwoFactor.MaxAttempts = 5
+	}
+	if c.TwoFactor.LockoutMinutes <= 0 {
+		c.TwoFactor.LockoutMinutes = 15
+	}
+	if c.TwoFactor.Secrets == nil {
+		c.TwoFactor.Secrets = make(map[int64]string)
+	}
+	if c.Audit.Path == "" {
+		c.Audit.Path = "audit.jsonl"
+	}
+	if c.Audit.MaxSizeMB <= 0 {
+		c.Audit.MaxSizeMB = 10
+	}
+	if c.Audit.MaxFiles <= 0 {
+		c.Audit.MaxFiles = 5
+	}
+	if c.Log.Level == "" {
+		c.Log.Level = "info"
+	}
+	if c.Log.Format == "" {
+		c.Log.Format = LogFormatText
+	}
+	if c.Log.File == "" {
+		c.Log.File = "remoteadmin.log"
+	}
+	if c.Metrics.ListenAddr == "" {
+		c.Metrics.ListenAddr = "127.0.0.1:9464"
+	}
+	if c.API.ListenAddr == "" {
+		c.API.ListenAddr = "127.0.0.1:8780"
+	}
+	if c.Schedules.File == "" {
+		c.Schedules.File = "schedules.json"
+	}
+	if c.Schedules.MissedRuns == "" {
+		c.Schedules.MissedRuns = MissedRunsSkip
+	}
+	if c.Outbox.File == "" {
+		c.Outbox.File = "outbox.json"
+	}
+	if c.Outbox.MaxAgeHours <= 0 {
+		c.Outbox.MaxAgeHours = 24
+	}
+	if c.Fleet.Name == "" {
+		c.Fleet.Name, _ = os.Hostname()
+	}
+	if c.Fleet.ListenAddr == "" {
+		c.Fleet.ListenAddr = ":8790"
+	}
+	if c.API.TimeoutSeconds <= 0 {
+		c.API.TimeoutSeconds = 300
+	}
+	if c.Dashboard.ListenAddr == "" {
+		c.Dashboard.ListenAddr = "127.0.0.1:8781"
+	}
+	if c.Dashboard.SessionMinutes <= 0 {
+		c.Dashboard.SessionMinutes = 480
+	}
+}
+
+// SaveTwoFactor writes the two_factor section back to secrets.json, leaving
+// every other key as the user wrote it.
+func (c *Config) SaveTwoFactor() error {
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	section, err := json.Marshal(c.TwoFactor)
+	if err != nil {
+		return err
+	}
+	raw["two_factor"] = section
+
+	data, err = json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(configFile, data, 0600)
+}
+
+func (c *Config) TwoFactorGrace() time.Duration {
+	return time.Duration(c.TwoFactor.GraceMinutes) * time.Minute
+}
+
+// TwoFactorLockout is both how long wrong codes are counted and how long a
+// user who sent too many is kept out.
+func (c *Config) TwoFactorLockout() time.Duration {
+	return time.Duration(c.TwoFactor.LockoutMinutes) * time.Minute
+}
+
+func (c *Config) IsSensitive(command string) bool {
+	for _, name := range c.TwoFactor.SensitiveCommands {
+		if strings.EqualFold(name, command) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) ConfirmTimeout() time.Duration {
+	return time.Duration(c.ConfirmTimeoutSeconds) * time.Second
+}
+
+func (c *Config) ShutdownTimeout() time.Duration {
+	return time.Duration(c.ShutdownTimeoutSeconds) * time.Second
+}
+
+func (c *Config) APITimeout() time.Duration {
+	return time.Duration(c.API.TimeoutSeconds) * time.Second
+}
+
+func (c *Config) OutboxMaxAge() time.Duration {
+	return time.Duration(c.Outbox.MaxAgeHours) * time.Hour
+}
+
+func (c *Config) DashboardSessionTTL() time.Duration {
+	return time.Duration(c.Dashboard.SessionMinutes) * time.Minute
+}
+
 func (c *Config) IsAuthorized(userID int64) bool {
+	_, ok := c.RoleOf(userID)
+	return ok
+}
+
+// RoleOf returns the user's role. Entries in "users" win; IDs that are only
+// listed in "authorized_users" get the default role.
+func (c *Config) RoleOf(userID int64) (Role, bool) {
+	for _, user := range c.Users {
+		if user.ID == userID {
+			return user.Role, true
+		}
+	}
+
 	for _, authorizedID := range c.AuthorizedUsers {
 		if userID == authorizedID {
+			return c.DefaultRole, true
+		}
+	}
+
+	return "", false
+}
+
+func (c *Config) HasRole(userID int64, role Role) bool {
+	userRole, ok := c.RoleOf(userID)
+	return ok && userRole.Includes(role)
+}
+
+// CanRun checks a command's required role, applying any override from
+// "command_roles".
+func (c *Config) CanRun(userID int64, command string, required Role) bool {
+	if override, ok := c.CommandRoles[command]; ok {
+		required = override
+	}
+	return c.HasRole(userID, required)
+}
+
+func (c *Config) chat(chatID int64) (Chat, bool) {
+	for _, chat := range c.Chats {
+		if chat.ID == chatID {
+			return chat, true
+		}
+	}
+	return Chat{}, false
+}
+
+// IsChatAllowed reports whether the bot may answer in a group chat at all.
+func (c *Config) IsChatAllowed(chatID int64) bool {
+	_, ok := c.chat(chatID)
+	return ok
+}
+
+// ChatAllows reports whether command may be used in the given group chat.
+// Private chats are not restricted by this.
+func (c *Config) ChatAllows(chatID int64, command string) bool {
+	chat, ok := c.chat(chatID)
+	if !ok {
+		return false
+	}
+
+	allowed := chat.Commands
+	if allowed == nil {
+		allowed = c.GroupCommands
+	}
+
+	for _, name := range allowed {
+		if name == AllCommands || strings.EqualFold(name, command) {
 			return true
 		}
 	}
 	return false
 }
 
+// AllowedChatIDs lists the group chats from "chats".
+func (c *Config) AllowedChatIDs() []int64 {
+	var ids []int64
+	for _, chat := range c.Chats {
+		ids = append(ids, chat.ID)
+	}
+	return ids
+}
+
+// AuthorizedIDs lists every authorized user, whatever their role.
+func (c *Config) AuthorizedIDs() []int64 {
+	var ids []int64
+	seen := make(map[int64]bool)
+
+	for _, user := range c.Users {
+		if !seen[user.ID] {
+			seen[user.ID] = true
+			ids = append(ids, user.ID)
+		}
+	}
+	for _, id := range c.AuthorizedUsers {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
 func (c *Config) ValidateConfig() error {
 	if c.BotToken == "" || c.BotToken == "YOUR_BOT_TOKEN_HERE" {
 		log.Fatal("Please set a valid bot token in secrets.json")
 	}
 
-	if len(c.AuthorizedUsers) == 0 {
+	if len(c.AuthorizedUsers) == 0 && len(c.Users) == 0 {
 		log.Fatal("Please add at least one authorized user ID in secrets.json")
 	}
 
+	if !c.DefaultRole.Valid() {
+		return fmt.Errorf("unknown default_role %q", c.DefaultRole)
+	}
+	for _, user := range c.Users {
+		if !user.Role.Valid() {
+			return fmt.Errorf("user %d has unknown role %q", user.ID, user.Role)
+		}
+	}
+	for command, role := range c.CommandRoles {
+		if !role.Valid() {
+			return fmt.Errorf("command_roles: /%s has unknown role %q", command, role)
+		}
+	}
+
+	switch c.Mode {
+	case ModePolling:
+	case ModeWebhook:
+		if c.Webhook.URL == "" {
+			return fmt.Errorf("webhook mode needs webhook.url")
+		}
+		if c.Webhook.CertFile == "" || c.Webhook.KeyFile == "" {
+			return fmt.Errorf("webhook mode needs webhook.cert_file and webhook.key_file")
+		}
+		if c.Webhook.SecretToken == "" {
+			return fmt.Errorf("webhook mode needs webhook.secret_token")
+		}
+	default:
+		return fmt.Errorf("unknown mode %q (use %q or %q)", c.Mode, ModePolling, ModeWebhook)
+	}
+
+	if c.Metrics.Enabled && !IsLoopbackAddr(c.Metrics.ListenAddr) {
+		return fmt.Errorf("metrics.listen_addr %q must be a localhost address", c.Metrics.ListenAddr)
+	}
+
+	if c.API.Enabled {
+		if err := c.validateAPI(); err != nil {
+			return err
+		}
+	}
+
+	if c.Dashboard.Enabled {
+		if err := c.validateDashboard(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.validateMacros(); err != nil {
+		return err
+	}
+
+	if err := c.validateFleet(); err != nil {
+		return err
+	}
+
+	switch c.Schedules.MissedRuns {
+	case MissedRunsSkip, MissedRunsCatchUp:
+	default:
+		return fmt.Errorf("unknown schedules.missed_runs %q (use %q or %q)", c.Schedules.MissedRuns, MissedRunsSkip, MissedRunsCatchUp)
+	}
+
+	switch c.Log.Format {
+	case LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("unknown log.format %q (use %q or %q)", c.Log.Format, LogFormatText, LogFormatJSON)
+	}
+
+	switch c.Unauthorized.Alert {
+	case AlertAll, AlertFirst, AlertNone:
+	default:
+		return fmt.Errorf("unknown unauthorized.alert %q (use %q, %q or %q)", c.Unauthorized.Alert, AlertAll, AlertFirst, AlertNone)
+	}
+
+	return nil
+}
+
+func (c *Config) validateAPI() error {
+	if len(c.API.Tokens) == 0 {
+		return fmt.Errorf("api needs at least one entry in api.tokens")
+	}
+	if (c.API.CertFile == "") != (c.API.KeyFile == "") {
+		return fmt.Errorf("api needs both api.cert_file and api.key_file, or neither")
+	}
+
+	seen := make(map[string]bool)
+	for _, token := range c.API.Tokens {
+		if len(token.Token) < MinAPITokenLength {
+			return fmt.Errorf("api token %q must be at least %d characters", token.Name, MinAPITokenLength)
+		}
+		if seen[token.Token] {
+			return fmt.Errorf("api token %q is used more than once", token.Name)
+		}
+		seen[token.Token] = true
+		if !c.IsAuthorized(token.UserID) {
+			return fmt.Errorf("api token %q belongs to user %d, who is not authorized", token.Name, token.UserID)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validateDashboard() error {
+	if len(c.Dashboard.Logins) == 0 {
+		return fmt.Errorf("dashboard needs at least one entry in dashboard.logins")
+	}
+	if (c.Dashboard.CertFile == "") != (c.Dashboard.KeyFile == "") {
+		return fmt.Errorf("dashboard needs both dashboard.cert_file and dashboard.key_file, or neither")
+	}
+
+	seen := make(map[string]bool)
+	for _, login := range c.Dashboard.Logins {
+		if login.Name == "" {
+			return fmt.Errorf("every dashboard login needs a name")
+		}
+		if seen[login.Name] {
+			return fmt.Errorf("dashboard login %q is listed more than once", login.Name)
+		}
+		seen[login.Name] = true
+		if len(login.Password) < MinDashboardPasswordLength {
+			return fmt.Errorf("dashboard login %q needs a password of at least %d characters", login.Name, MinDashboardPasswordLength)
+		}
+		if !c.IsAuthorized(login.UserID) {
+			return fmt.Errorf("dashboard login %q belongs to user %d, who is not authorized", login.Name, login.UserID)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validateFleet() error {
+	switch c.Fleet.Role {
+	case "":
+		return nil
+	case FleetCoordinator:
+		// Frames after the handshake carry commands to run, so the link
+		// must not be readable or writable by anyone on the network.
+		if c.Fleet.CertFile == "" || c.Fleet.KeyFile == "" {
+			return fmt.Errorf("fleet coordinators need fleet.cert_file and fleet.key_file")
+		}
+	case FleetAgent:
+		if c.Fleet.CoordinatorAddr == "" {
+			return fmt.Errorf("fleet agents need fleet.coordinator_addr")
+		}
+		if !c.Fleet.TLS {
+			return fmt.Errorf("fleet agents need fleet.tls")
+		}
+	default:
+		return fmt.Errorf("unknown fleet.role %q (use %q or %q)", c.Fleet.Role, FleetCoordinator, FleetAgent)
+	}
+
+	if len(c.Fleet.Secret) < MinFleetSecretLength {
+		return fmt.Errorf("fleet.secret must be at least %d characters", MinFleetSecretLength)
+	}
+	if !validHostName(c.Fleet.Name) {
+		return fmt.Errorf("fleet.name %q must be up to 64 letters, digits, '-', '_' or '.'", c.Fleet.Name)
+	}
 	return nil
 }
+
+// validateMacros checks what can be checked without the command registry.
+// The bot checks that names and targets match real commands when it starts.
+func (c *Config) validateMacros() error {
+	for name, target := range c.Aliases {
+		if !validCommandName(name) {
+			return fmt.Errorf("alias %q must be a single word of lowercase letters, digits or _", name)
+		}
+		if !strings.HasPrefix(target, "/") {
+			return fmt.Errorf("alias %q must point at a command line starting with /", name)
+		}
+		if _, ok := c.Macros[name]; ok {
+			return fmt.Errorf("%q is both an alias and a macro", name)
+		}
+	}
+
+	for name, macro := range c.Macros {
+		if !validCommandName(name) {
+			return fmt.Errorf("macro %q must be a single word of lowercase letters, digits or _", name)
+		}
+		if len(macro.Steps) == 0 {
+			return fmt.Errorf("macro %q has no steps", name)
+		}
+
+		params := make(map[string]bool)
+		for _, param := range macro.Params {
+			if !validCommandName(param) {
+				return fmt.Errorf("macro %q: parameter %q must be a single word of lowercase letters, digits or _", name, param)
+			}
+			params[param] = true
+		}
+
+		for _, step := range macro.Steps {
+			if !strings.HasPrefix(step, "/") {
+				return fmt.Errorf("macro %q: step %q must start with /", name, step)
+			}
+			if c.runsMacro(step) {
+				return fmt.Errorf("macro %q: step %q runs another macro, which is not supported", name, step)
+			}
+			for _, match := range macroParamPattern.FindAllStringSubmatch(step, -1) {
+				if !params[match[1]] {
+					return fmt.Errorf("macro %q: step %q uses {%s}, which is not in params", name, step, match[1])
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// runsMacro reports whether a command line starts a macro, directly or
+// through an alias.
+func (c *Config) runsMacro(line string) bool {
+	command, _, _ := strings.Cut(strings.TrimPrefix(line, "/"), " ")
+	command = strings.ToLower(command)
+	if target, ok := c.Aliases[command]; ok {
+		command, _, _ = strings.Cut(strings.TrimPrefix(target, "/"), " ")
+		command = strings.ToLower(command)
+	}
+	_, ok := c.Macros[command]
+	return ok
+}
+
+// macroParamPattern finds {param} placeholders in macro steps.
+var macroParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+func validCommandName(name string) bool {
+	if name == "" || len(name) > 32 {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func validHostName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsLoopbackAddr reports whether a listen address is only reachable from
+// this machine.
+func IsLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFleet(t *testing.T) {
+	secret := strings.Repeat("s", MinFleetSecretLength)
+
+	tests := []struct {
+		name    string
+		fleet   FleetConfig
+		wantErr string
+	}{
+		{"off", FleetConfig{}, ""},
+		{"coordinator", FleetConfig{Role: FleetCoordinator, Name: "hq", Secret: secret, CertFile: "c.pem", KeyFile: "k.pem"}, ""},
+		{"coordinator without tls", FleetConfig{Role: FleetCoordinator, Name: "hq", Secret: secret}, "cert_file"},
+		{"coordinator without key", FleetConfig{Role: FleetCoordinator, Name: "hq", Secret: secret, CertFile: "c.pem"}, "key_file"},
+		{"agent", FleetConfig{Role: FleetAgent, Name: "web-1", Secret: secret, CoordinatorAddr: "hq:8790", TLS: true}, ""},
+		{"agent without tls", FleetConfig{Role: FleetAgent, Name: "web-1", Secret: secret, CoordinatorAddr: "hq:8790"}, "fleet.tls"},
+		{"agent without coordinator", FleetConfig{Role: FleetAgent, Name: "web-1", Secret: secret, TLS: true}, "coordinator_addr"},
+		{"short secret", FleetConfig{Role: FleetAgent, Name: "web-1", Secret: "short", CoordinatorAddr: "hq:8790", TLS: true}, "fleet.secret"},
+		{"bad name", FleetConfig{Role: FleetAgent, Name: "web 1", Secret: secret, CoordinatorAddr: "hq:8790", TLS: true}, "fleet.name"},
+		{"unknown role", FleetConfig{Role: "peer"}, "unknown fleet.role"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Fleet: tt.fleet}
+			err := c.validateFleet()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMacros(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases map[string]string
+		macros  map[string]Macro
+		wantErr string
+	}{
+		{
+			name:    "alias to a command",
+			aliases: map[string]string{"top": "/processes --sort cpu"},
+		},
+		{
+			name:    "alias to a macro",
+			aliases: map[string]string{"c": "/check"},
+			macros:  map[string]Macro{"check": {Steps: []string{"/info", "/ssm"}}},
+		},
+		{
+			name:    "macro step through an alias",
+			aliases: map[string]string{"top": "/processes --sort cpu"},
+			macros:  map[string]Macro{"check": {Steps: []string{"/info", "/top --limit 3"}}},
+		},
+		{
+			name:   "params",
+			macros: map[string]Macro{"stop": {Params: []string{"pid"}, Steps: []string{"/kill {pid}"}}},
+		},
+		{
+			name:    "alias not a command line",
+			aliases: map[string]string{"top": "processes"},
+			wantErr: "starting with /",
+		},
+		{
+			name:    "bad alias name",
+			aliases: map[string]string{"Top": "/processes"},
+			wantErr: "single word",
+		},
+		{
+			name:    "alias and macro share a name",
+			aliases: map[string]string{"check": "/info"},
+			macros:  map[string]Macro{"check": {Steps: []string{"/info"}}},
+			wantErr: "both an alias and a macro",
+		},
+		{
+			name:    "no steps",
+			macros:  map[string]Macro{"check": {}},
+			wantErr: "no steps",
+		},
+		{
+			name:    "unknown param",
+			macros:  map[string]Macro{"stop": {Steps: []string{"/kill {pid}"}}},
+			wantErr: "uses {pid}",
+		},
+		{
+			name:    "macro runs a macro",
+			macros:  map[string]Macro{"a": {Steps: []string{"/info"}}, "b": {Steps: []string{"/A"}}},
+			wantErr: "runs another macro",
+		},
+		{
+			name:    "macro runs a macro through an alias",
+			aliases: map[string]string{"x": "/a"},
+			macros:  map[string]Macro{"a": {Steps: []string{"/info"}}, "b": {Steps: []string{"/x"}}},
+			wantErr: "runs another macro",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Aliases: tt.aliases, Macros: tt.macros}
+			err := c.validateMacros()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"remoteadmin/audit"
 	"remoteadmin/bot"
 	"remoteadmin/config"
 	"runtime"
@@ -48,6 +49,11 @@ func (h *Handler) handleConsoleInput() {
 func (h *Handler) processCommand(input string) {
 	command := strings.ToLower(input)
 
+	if fields := strings.Fields(input); len(fields) > 0 && (fields[0] == "5" || strings.EqualFold(fields[0], "audit")) {
+		h.showAudit(fields[1:])
+		return
+	}
+
 	switch command {
 	case "1", "ping admin":
 		h.pingAdmin()
@@ -56,8 +62,7 @@ func (h *Handler) processCommand(input string) {
 	case "3", "help", "commands":
 		h.showCommands()
 	case "4", "exit", "quit":
-		fmt.Println("> Goodbye!")
-		os.Exit(0)
+		h.bot.Shutdown()
 	default:
 		fmt.Printf("> Unknown command: %s\n", input)
 		fmt.Println("> Type 'help' for available commands")
@@ -88,12 +93,38 @@ func (h *Handler) sendMessage() {
 	fmt.Println("> Message sent to all admins")
 }
 
+func (h *Handler) showAudit(args []string) {
+	filter, err := audit.ParseFilter(args)
+	if err != nil {
+		fmt.Printf("> %v\n", err)
+		fmt.Println("> Usage: audit [user] [since]")
+		return
+	}
+	filter.Limit = 50
+
+	entries, err := h.bot.AuditLog().Query(filter)
+	if err != nil {
+		fmt.Printf("> Failed to read audit log: %v\n", err)
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("> No matching audit entries")
+		return
+	}
+
+	for _, entry := range entries {
+		fmt.Println(entry.String())
+	}
+}
+
 func (h *Handler) showCommands() {
 	fmt.Println("Commands:")
 	fmt.Println("1. Ping admin")
 	fmt.Println("2. Send message")
 	fmt.Println("3. help - Show this menu")
 	fmt.Println("4. exit - Exit the program")
+	fmt.Println("5. audit [user] [since] - Show recent bot commands")
 }
 
 func (h *Handler) ShowCommands() {
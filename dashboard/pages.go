@@ -0,0 +1,42 @@
+package dashboard
+
+import (
+	"embed"
+	"html/template"
+	"io"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+var pages = template.Must(template.ParseFS(templateFiles, "templates/*.html"))
+
+// Panels says which parts of the dashboard the logged-in user may see or
+// use. They follow the roles of the matching bot commands.
+type Panels struct {
+	Stats     bool
+	Processes bool
+	Kill      bool
+	Browser   bool
+	Audit     bool
+}
+
+type IndexPage struct {
+	Host   string
+	Name   string
+	CSRF   string
+	Panels Panels
+}
+
+type LoginPage struct {
+	Host  string
+	Error string
+}
+
+func RenderIndex(w io.Writer, page IndexPage) error {
+	return pages.ExecuteTemplate(w, "index.html", page)
+}
+
+func RenderLogin(w io.Writer, page LoginPage) error {
+	return pages.ExecuteTemplate(w, "login.html", page)
+}
@@ -0,0 +1,87 @@
+package dashboard
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// CookieName holds the session ID in the browser.
+const CookieName = "remoteadmin_session"
+
+// Session is a logged-in browser. CSRF must come back in a header on every
+// request that changes something.
+type Session struct {
+	ID      string
+	CSRF    string
+	UserID  int64
+	Name    string
+	Expires time.Time
+}
+
+// Sessions keeps logins in memory; restarting the bot logs everyone out.
+type Sessions struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]Session
+}
+
+func NewSessions(ttl time.Duration) *Sessions {
+	return &Sessions{
+		ttl:      ttl,
+		sessions: make(map[string]Session),
+	}
+}
+
+func (s *Sessions) Create(userID int64, name string) Session {
+	now := time.Now()
+	session := Session{
+		ID:      randomToken(),
+		CSRF:    randomToken(),
+		UserID:  userID,
+		Name:    name,
+		Expires: now.Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, old := range s.sessions {
+		if now.After(old.Expires) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[session.ID] = session
+
+	return session
+}
+
+func (s *Sessions) Get(id string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return Session{}, false
+	}
+	if time.Now().After(session.Expires) {
+		delete(s.sessions, id)
+		return Session{}, false
+	}
+	return session, true
+}
+
+func (s *Sessions) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+}
+
+func randomToken() string {
+	buf := make([]byte, 32)
+	// crypto/rand.Read never fails on supported platforms.
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
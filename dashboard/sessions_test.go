@@ -0,0 +1,46 @@
+package dashboard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	sessions := NewSessions(time.Hour)
+
+	a := sessions.Create(1, "alice")
+	b := sessions.Create(1, "alice")
+	if a.ID == b.ID || a.ID == a.CSRF {
+		t.Fatal("tokens are reused")
+	}
+
+	got, ok := sessions.Get(a.ID)
+	if !ok || got.UserID != 1 || got.CSRF != a.CSRF {
+		t.Fatalf("Get = %+v, %v", got, ok)
+	}
+
+	sessions.Delete(a.ID)
+	if _, ok := sessions.Get(a.ID); ok {
+		t.Error("deleted session still valid")
+	}
+	if _, ok := sessions.Get(b.ID); !ok {
+		t.Error("deleting one session logged out another")
+	}
+	if _, ok := sessions.Get("forged"); ok {
+		t.Error("unknown ID accepted")
+	}
+}
+
+func TestSessionsExpire(t *testing.T) {
+	sessions := NewSessions(-time.Second)
+
+	s := sessions.Create(1, "alice")
+	if _, ok := sessions.Get(s.ID); ok {
+		t.Error("expired session still valid")
+	}
+
+	sessions.Create(2, "bob")
+	if len(sessions.sessions) != 1 {
+		t.Errorf("%d sessions kept, want expired ones dropped", len(sessions.sessions))
+	}
+}
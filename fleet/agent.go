@@ -0,0 +1,329 @@
+package fleet
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"remoteadmin/transport"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	dialTimeout = 15 * time.Second
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+
+	// resultTimeout bounds how long a send waits for the coordinator,
+	// which may be uploading a recording or waiting out a rate limit.
+	resultTimeout = 5 * time.Minute
+)
+
+// Agent keeps a link to the coordinator open, reconnecting as needed. It
+// delivers the updates the coordinator forwards and is the messenger for
+// this host's replies.
+type Agent struct {
+	addr      string
+	name      string
+	secret    string
+	tlsConfig *tls.Config
+
+	updates    chan tgbotapi.Update
+	updatesOff chan struct{}
+	offOnce    sync.Once
+	stop       chan struct{}
+	stopped    chan struct{}
+
+	mu      sync.Mutex
+	running bool
+	link    *link
+	nextID  uint64
+	pending map[uint64]chan frame
+}
+
+// The link always uses TLS; a nil tlsConfig checks the coordinator against
+// the system roots.
+func NewAgent(addr, name, secret string, tlsConfig *tls.Config) *Agent {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return &Agent{
+		addr:       addr,
+		name:       name,
+		secret:     secret,
+		tlsConfig:  tlsConfig,
+		updates:    make(chan tgbotapi.Update),
+		updatesOff: make(chan struct{}),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+		pending:    make(map[uint64]chan frame),
+	}
+}
+
+// Updates delivers forwarded updates until StopUpdates.
+func (a *Agent) Updates() <-chan tgbotapi.Update {
+	return a.updates
+}
+
+// StopUpdates drops further updates but keeps the link up, so replies and
+// shutdown notices still go out.
+func (a *Agent) StopUpdates() {
+	a.offOnce.Do(func() { close(a.updatesOff) })
+}
+
+// Run connects and reconnects until Close is called.
+func (a *Agent) Run() {
+	a.mu.Lock()
+	a.running = true
+	a.mu.Unlock()
+	defer close(a.stopped)
+
+	select {
+	case <-a.stop:
+		return
+	default:
+	}
+
+	delay := minReconnectDelay
+	for {
+		started := time.Now()
+		err := a.connect()
+
+		select {
+		case <-a.stop:
+			return
+		default:
+		}
+
+		// A link that stayed up for a while was fine; start backing off
+		// from scratch.
+		if time.Since(started) > maxReconnectDelay {
+			delay = minReconnectDelay
+		}
+		slog.Warn("fleet link down, reconnecting", "coordinator", a.addr, "error", err, "retry_in", delay)
+
+		select {
+		case <-a.stop:
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+func (a *Agent) Close() {
+	select {
+	case <-a.stop:
+		return
+	default:
+	}
+	close(a.stop)
+
+	a.mu.Lock()
+	if a.link != nil {
+		a.link.close()
+	}
+	running := a.running
+	a.mu.Unlock()
+
+	if running {
+		<-a.stopped
+	}
+}
+
+func (a *Agent) connect() error {
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: pingInterval}
+	conn, err := tls.DialWithDialer(dialer, "tcp", a.addr, a.tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	l := newLink(conn)
+	defer l.close()
+
+	if err := a.handshake(l); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.link = l
+	a.mu.Unlock()
+	slog.Info("connected to fleet coordinator", "coordinator", a.addr, "host", a.name)
+
+	done := make(chan struct{})
+	go a.ping(l, done)
+
+	err = a.readCoordinator(l)
+
+	close(done)
+	a.mu.Lock()
+	a.link = nil
+	for id, ch := range a.pending {
+		ch <- frame{Type: frameResult, ID: id, Error: ErrNotConnected.Error()}
+		delete(a.pending, id)
+	}
+	a.mu.Unlock()
+
+	return err
+}
+
+func (a *Agent) handshake(l *link) error {
+	challenge, err := l.read(maxHandshakeFrame, handshakeTimeout)
+	if err != nil {
+		return err
+	}
+	if challenge.Type != frameChallenge || challenge.Nonce == "" {
+		return errors.New("coordinator did not send a challenge")
+	}
+
+	nonce := newNonce()
+	hello := frame{
+		Type:  frameHello,
+		Host:  a.name,
+		Nonce: nonce,
+		MAC:   sign(a.secret, "agent", challenge.Nonce, a.name),
+	}
+	if err := l.write(hello); err != nil {
+		return err
+	}
+
+	welcome, err := l.read(maxHandshakeFrame, handshakeTimeout)
+	if err != nil {
+		return err
+	}
+	switch {
+	case welcome.Type == frameRejected:
+		return fmt.Errorf("coordinator refused the link: %s", welcome.Error)
+	case welcome.Type != frameWelcome:
+		return fmt.Errorf("unexpected %q frame during handshake", welcome.Type)
+	case !validMAC(a.secret, "coordinator", nonce, a.name, welcome.MAC):
+		return errors.New("coordinator does not know the fleet secret")
+	}
+	return nil
+}
+
+func (a *Agent) ping(l *link, done chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := l.write(frame{Type: framePing}); err != nil {
+				l.close()
+				return
+			}
+		}
+	}
+}
+
+func (a *Agent) readCoordinator(l *link) error {
+	for {
+		f, err := l.read(maxFrame, idleTimeout)
+		if err != nil {
+			return err
+		}
+
+		switch f.Type {
+		case frameUpdate:
+			if f.Update == nil {
+				continue
+			}
+			select {
+			case a.updates <- *f.Update:
+			case <-a.updatesOff:
+			case <-a.stop:
+				return nil
+			}
+		case frameResult:
+			a.mu.Lock()
+			ch, ok := a.pending[f.ID]
+			delete(a.pending, f.ID)
+			a.mu.Unlock()
+			if ok {
+				ch <- f
+			}
+		case framePong:
+		default:
+			slog.Warn("unexpected frame from fleet coordinator", "type", f.Type)
+		}
+	}
+}
+
+// call sends a request to the coordinator and waits for its result. The
+// coordinator has already retried whatever it could, so its errors are
+// final; only a missing link is worth retrying.
+func (a *Agent) call(f frame) (frame, error) {
+	ch := make(chan frame, 1)
+
+	a.mu.Lock()
+	l := a.link
+	if l == nil {
+		a.mu.Unlock()
+		return frame{}, ErrNotConnected
+	}
+	a.nextID++
+	f.ID = a.nextID
+	a.pending[f.ID] = ch
+	a.mu.Unlock()
+
+	if err := l.write(f); err != nil {
+		a.mu.Lock()
+		delete(a.pending, f.ID)
+		a.mu.Unlock()
+		return frame{}, err
+	}
+
+	select {
+	case result := <-ch:
+		if result.Error != "" && result.Error != ErrNotConnected.Error() {
+			return result, &transport.SendError{Err: errors.New(result.Error), Permanent: true}
+		}
+		if result.Error != "" {
+			return result, ErrNotConnected
+		}
+		return result, nil
+	case <-time.After(resultTimeout):
+		a.mu.Lock()
+		delete(a.pending, f.ID)
+		a.mu.Unlock()
+		// It may still get through, so don't send it again.
+		return frame{}, &transport.SendError{Err: errors.New("coordinator did not answer in time"), Permanent: true}
+	}
+}
+
+func (a *Agent) Send(msg transport.Message) (int, error) {
+	result, err := a.call(frame{Type: frameSend, Message: &msg})
+	return result.MessageID, err
+}
+
+// SendFile sends the file's contents; only its base name travels with it.
+func (a *Agent) SendFile(file transport.File) error {
+	data, err := os.ReadFile(file.Path)
+	if err != nil {
+		return err
+	}
+	file.Path = filepath.Base(file.Path)
+
+	_, err = a.call(frame{Type: frameSendFile, File: &file, Data: data})
+	return err
+}
+
+func (a *Agent) Edit(messageID int, msg transport.Message) error {
+	_, err := a.call(frame{Type: frameEdit, MessageID: messageID, Message: &msg})
+	return err
+}
+
+func (a *Agent) AnswerCallback(callbackID string, text string) error {
+	_, err := a.call(frame{Type: frameAnswer, CallbackID: callbackID, Text: text})
+	return err
+}
@@ -0,0 +1,332 @@
+package fleet
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"remoteadmin/transport"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxOwnedMessages is how many agent messages with buttons the coordinator
+// remembers, so a button press reaches the host that sent the buttons.
+const maxOwnedMessages = 1000
+
+// Host is a connected agent.
+type Host struct {
+	Name      string
+	Addr      string
+	Connected time.Time
+	LastSeen  time.Time
+}
+
+type agentConn struct {
+	link *link
+	host Host
+}
+
+type messageKey struct {
+	chatID    int64
+	messageID int
+}
+
+// Coordinator accepts agents and passes Telegram updates to them. Their
+// replies go out through the messenger messengerFor returns for their name.
+type Coordinator struct {
+	secret       string
+	messengerFor func(host string) transport.Messenger
+
+	mu       sync.Mutex
+	listener net.Listener
+	agents   map[string]*agentConn
+	selected map[int64]string
+	owners   map[messageKey]string
+	order    []messageKey
+}
+
+func NewCoordinator(secret string, messengerFor func(host string) transport.Messenger) *Coordinator {
+	return &Coordinator{
+		secret:       secret,
+		messengerFor: messengerFor,
+		agents:       make(map[string]*agentConn),
+		selected:     make(map[int64]string),
+		owners:       make(map[messageKey]string),
+	}
+}
+
+// Serve accepts agents until Close is called.
+func (c *Coordinator) Serve(listener net.Listener) error {
+	c.mu.Lock()
+	c.listener = listener
+	c.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go c.serveAgent(conn)
+	}
+}
+
+func (c *Coordinator) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.listener != nil {
+		c.listener.Close()
+	}
+	for _, agent := range c.agents {
+		agent.link.close()
+	}
+}
+
+// Hosts lists the connected agents by name.
+func (c *Coordinator) Hosts() []Host {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hosts := make([]Host, 0, len(c.agents))
+	for _, agent := range c.agents {
+		hosts = append(hosts, agent.host)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	return hosts
+}
+
+// Lookup finds a connected agent by name, ignoring case.
+func (c *Coordinator) Lookup(name string) (Host, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	agent, ok := c.agents[strings.ToLower(name)]
+	if !ok {
+		return Host{}, false
+	}
+	return agent.host, true
+}
+
+// Select makes host the target of the user's plain commands. An empty host
+// goes back to the coordinator itself.
+func (c *Coordinator) Select(userID int64, host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if host == "" {
+		delete(c.selected, userID)
+		return
+	}
+	c.selected[userID] = host
+}
+
+// Selected returns the user's chosen host, or "" for the coordinator.
+func (c *Coordinator) Selected(userID int64) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.selected[userID]
+}
+
+// Owner returns the agent that sent a message with buttons, if any.
+func (c *Coordinator) Owner(chatID int64, messageID int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	host, ok := c.owners[messageKey{chatID, messageID}]
+	return host, ok
+}
+
+// Forward hands an update to an agent to handle as if it had received it
+// from Telegram itself.
+func (c *Coordinator) Forward(host string, update tgbotapi.Update) error {
+	c.mu.Lock()
+	agent, ok := c.agents[strings.ToLower(host)]
+	c.mu.Unlock()
+	if !ok {
+		return ErrUnknownHost
+	}
+
+	return agent.link.write(frame{Type: frameUpdate, Update: &update})
+}
+
+func (c *Coordinator) serveAgent(conn net.Conn) {
+	l := newLink(conn)
+	defer l.close()
+
+	host, err := c.handshake(l)
+	if err != nil {
+		slog.Warn("fleet agent rejected", "remote", conn.RemoteAddr().String(), "error", err)
+		return
+	}
+
+	agent := &agentConn{
+		link: l,
+		host: Host{Name: host, Addr: conn.RemoteAddr().String(), Connected: time.Now(), LastSeen: time.Now()},
+	}
+	key := strings.ToLower(host)
+
+	c.mu.Lock()
+	// A reconnecting agent replaces its old link, which may not have
+	// timed out yet.
+	if old, ok := c.agents[key]; ok {
+		old.link.close()
+	}
+	c.agents[key] = agent
+	c.mu.Unlock()
+
+	slog.Info("fleet agent connected", "host", host, "remote", agent.host.Addr)
+	err = c.readAgent(agent)
+
+	c.mu.Lock()
+	if c.agents[key] == agent {
+		delete(c.agents, key)
+	}
+	c.mu.Unlock()
+
+	slog.Info("fleet agent disconnected", "host", host, "error", err)
+}
+
+func (c *Coordinator) handshake(l *link) (string, error) {
+	nonce := newNonce()
+	if err := l.write(frame{Type: frameChallenge, Nonce: nonce}); err != nil {
+		return "", err
+	}
+
+	hello, err := l.read(maxHandshakeFrame, handshakeTimeout)
+	if err != nil {
+		return "", err
+	}
+	if hello.Type != frameHello || !validHostName(hello.Host) || hello.Nonce == "" {
+		l.write(frame{Type: frameRejected, Error: "bad hello"})
+		return "", errors.New("bad hello")
+	}
+	if !validMAC(c.secret, "agent", nonce, hello.Host, hello.MAC) {
+		l.write(frame{Type: frameRejected, Error: "wrong secret"})
+		return "", fmt.Errorf("wrong secret from %q", hello.Host)
+	}
+
+	welcome := frame{Type: frameWelcome, MAC: sign(c.secret, "coordinator", hello.Nonce, hello.Host)}
+	if err := l.write(welcome); err != nil {
+		return "", err
+	}
+	return hello.Host, nil
+}
+
+// readAgent carries out the agent's sends one at a time, which keeps its
+// replies in order.
+func (c *Coordinator) readAgent(agent *agentConn) error {
+	messenger := c.messengerFor(agent.host.Name)
+
+	for {
+		f, err := agent.link.read(maxFrame, idleTimeout)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		agent.host.LastSeen = time.Now()
+		c.mu.Unlock()
+
+		result := frame{Type: frameResult, ID: f.ID}
+		switch f.Type {
+		case framePing:
+			result = frame{Type: framePong}
+		case frameSend:
+			if f.Message == nil {
+				err = errors.New("send without a message")
+				break
+			}
+			result.MessageID, err = messenger.Send(*f.Message)
+			if err == nil && f.Message.Keyboard != nil {
+				c.remember(f.Message.ChatID, result.MessageID, agent.host.Name)
+			}
+		case frameSendFile:
+			if f.File == nil {
+				err = errors.New("send_file without a file")
+				break
+			}
+			err = sendFileData(messenger, *f.File, f.Data)
+		case frameEdit:
+			if f.Message == nil {
+				err = errors.New("edit without a message")
+				break
+			}
+			err = messenger.Edit(f.MessageID, *f.Message)
+			if err == nil && f.Message.Keyboard != nil {
+				c.remember(f.Message.ChatID, f.MessageID, agent.host.Name)
+			}
+		case frameAnswer:
+			err = messenger.AnswerCallback(f.CallbackID, f.Text)
+		default:
+			err = fmt.Errorf("unexpected %q frame", f.Type)
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if err := agent.link.write(result); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Coordinator) remember(chatID int64, messageID int, host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := messageKey{chatID, messageID}
+	if _, ok := c.owners[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.owners[key] = host
+
+	if len(c.order) > maxOwnedMessages {
+		delete(c.owners, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// sendFileData writes a file received from an agent to a temp directory,
+// keeping its name, and sends it from there.
+func sendFileData(messenger transport.Messenger, file transport.File, data []byte) error {
+	dir, err := os.MkdirTemp("", "remoteadmin-fleet-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Base(file.Path)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		name = "file"
+	}
+	file.Path = filepath.Join(dir, name)
+	if err := os.WriteFile(file.Path, data, 0600); err != nil {
+		return err
+	}
+	return messenger.SendFile(file)
+}
+
+// validHostName accepts names that are easy to type after @ or /use.
+func validHostName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
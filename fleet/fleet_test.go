@@ -0,0 +1,172 @@
+package fleet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"remoteadmin/transport"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const testSecret = "0123456789abcdef01234567"
+
+func TestValidMAC(t *testing.T) {
+	good := sign(testSecret, "agent", "nonce", "web-1")
+
+	tests := []struct {
+		name                      string
+		secret, role, nonce, host string
+		want                      bool
+	}{
+		{"match", testSecret, "agent", "nonce", "web-1", true},
+		{"other secret", testSecret + "x", "agent", "nonce", "web-1", false},
+		{"replayed as coordinator", testSecret, "coordinator", "nonce", "web-1", false},
+		{"other nonce", testSecret, "agent", "nonce2", "web-1", false},
+		{"other host", testSecret, "agent", "nonce", "web-2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validMAC(tt.secret, tt.role, tt.nonce, tt.host, good); got != tt.want {
+				t.Errorf("validMAC = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testTLS returns a listener config with a throwaway certificate and an
+// agent config that trusts it.
+func testTLS(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "coordinator"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	server = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}
+	client = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	return server, client
+}
+
+func startCoordinator(t *testing.T, recorder *transport.Recorder) (*Coordinator, string, *tls.Config) {
+	t.Helper()
+
+	serverTLS, clientTLS := testTLS(t)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverTLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCoordinator(testSecret, func(string) transport.Messenger { return recorder })
+	go c.Serve(listener)
+	t.Cleanup(c.Close)
+	return c, listener.Addr().String(), clientTLS
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	recorder := transport.NewRecorder()
+	c, addr, clientTLS := startCoordinator(t, recorder)
+
+	agent := NewAgent(addr, "web-1", testSecret, clientTLS)
+	go agent.Run()
+	t.Cleanup(agent.Close)
+
+	waitFor(t, "agent to connect", func() bool {
+		_, ok := c.Lookup("WEB-1")
+		return ok
+	})
+
+	update := tgbotapi.Update{UpdateID: 7, Message: &tgbotapi.Message{Text: "/info"}}
+	if err := c.Forward("web-1", update); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-agent.Updates():
+		if got.UpdateID != 7 || got.Message.Text != "/info" {
+			t.Errorf("forwarded update = %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("update not delivered")
+	}
+
+	if _, err := agent.Send(transport.NewMessage(42, "hi")); err != nil {
+		t.Fatal(err)
+	}
+	messages := recorder.Messages()
+	if len(messages) != 1 || messages[0].ChatID != 42 || messages[0].Text != "hi" {
+		t.Errorf("coordinator sent %+v", messages)
+	}
+}
+
+func TestHandshakeRejectsWrongSecret(t *testing.T) {
+	c, addr, clientTLS := startCoordinator(t, transport.NewRecorder())
+
+	agent := NewAgent(addr, "web-1", testSecret+"x", clientTLS)
+	go agent.Run()
+	t.Cleanup(agent.Close)
+
+	time.Sleep(300 * time.Millisecond)
+	if hosts := c.Hosts(); len(hosts) != 0 {
+		t.Errorf("agent with the wrong secret connected: %+v", hosts)
+	}
+	if _, err := agent.Send(transport.NewMessage(42, "hi")); err != ErrNotConnected {
+		t.Errorf("Send = %v, want ErrNotConnected", err)
+	}
+}
+
+func TestAgentRefusesUntrustedCoordinator(t *testing.T) {
+	c, addr, _ := startCoordinator(t, transport.NewRecorder())
+
+	agent := NewAgent(addr, "web-1", testSecret, &tls.Config{MinVersion: tls.VersionTLS12})
+	go agent.Run()
+	t.Cleanup(agent.Close)
+
+	time.Sleep(300 * time.Millisecond)
+	if hosts := c.Hosts(); len(hosts) != 0 {
+		t.Errorf("agent connected to a coordinator it can't verify: %+v", hosts)
+	}
+}
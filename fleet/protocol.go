@@ -0,0 +1,163 @@
+package fleet
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"remoteadmin/transport"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// The link is one JSON frame per line. The coordinator opens with a
+// challenge; the agent answers with its name, a MAC over the challenge and
+// a challenge of its own, which the coordinator's welcome answers. Both
+// sides prove they know the shared secret without sending it.
+const (
+	frameChallenge = "challenge"
+	frameHello     = "hello"
+	frameWelcome   = "welcome"
+	frameRejected  = "rejected"
+	framePing      = "ping"
+	framePong      = "pong"
+
+	// Coordinator to agent.
+	frameUpdate = "update"
+
+	// Agent to coordinator, each answered by a result frame with the
+	// same ID.
+	frameSend     = "send"
+	frameSendFile = "send_file"
+	frameEdit     = "edit"
+	frameAnswer   = "answer"
+	frameResult   = "result"
+)
+
+const (
+	// maxHandshakeFrame bounds what an unauthenticated peer can make us
+	// buffer.
+	maxHandshakeFrame = 4 << 10
+
+	// maxFrame leaves room for a recording sent as a file.
+	maxFrame = 128 << 20
+
+	handshakeTimeout = 10 * time.Second
+	writeTimeout     = 2 * time.Minute
+
+	// pingInterval keeps idle links open through NATs; a peer silent for
+	// idleTimeout is considered gone.
+	pingInterval = 30 * time.Second
+	idleTimeout  = 3 * pingInterval
+)
+
+var (
+	ErrNotConnected = errors.New("not connected to the coordinator")
+	ErrUnknownHost  = errors.New("unknown or disconnected host")
+)
+
+type frame struct {
+	Type string `json:"type"`
+	ID   uint64 `json:"id,omitempty"`
+
+	Host  string `json:"host,omitempty"`
+	Nonce string `json:"nonce,omitempty"`
+	MAC   string `json:"mac,omitempty"`
+
+	Update *tgbotapi.Update `json:"update,omitempty"`
+
+	Message    *transport.Message `json:"message,omitempty"`
+	MessageID  int                `json:"message_id,omitempty"`
+	File       *transport.File    `json:"file,omitempty"`
+	Data       []byte             `json:"data,omitempty"`
+	CallbackID string             `json:"callback_id,omitempty"`
+	Text       string             `json:"text,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// link is one end of a connection. Writes may come from any goroutine;
+// reads come from the single goroutine that owns the link.
+type link struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	writeMu sync.Mutex
+}
+
+func newLink(conn net.Conn) *link {
+	return &link{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (l *link) write(f frame) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+
+	l.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err = l.conn.Write(data)
+	return err
+}
+
+// read waits up to timeout for the next frame, refusing frames over limit
+// bytes.
+func (l *link) read(limit int, timeout time.Duration) (frame, error) {
+	l.conn.SetReadDeadline(time.Now().Add(timeout))
+
+	var line []byte
+	for {
+		chunk, err := l.reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > limit {
+			return frame{}, fmt.Errorf("frame larger than %d bytes", limit)
+		}
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return frame{}, err
+		}
+	}
+
+	var f frame
+	if err := json.Unmarshal(line, &f); err != nil {
+		return frame{}, fmt.Errorf("bad frame: %w", err)
+	}
+	return f, nil
+}
+
+func (l *link) close() error {
+	return l.conn.Close()
+}
+
+func newNonce() string {
+	buf := make([]byte, 32)
+	// crypto/rand.Read never fails on supported platforms.
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// sign proves knowledge of the secret for one side of the handshake. The
+// role keeps an agent's MAC from being replayed as the coordinator's.
+func sign(secret, role, nonce, host string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(role + "\n" + nonce + "\n" + host))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validMAC(secret, role, nonce, host, got string) bool {
+	want := sign(secret, role, nonce, host)
+	return hmac.Equal([]byte(want), []byte(got))
+}
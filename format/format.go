@@ -0,0 +1,206 @@
+package format
+
+import (
+	"fmt"
+	"html"
+	"remoteadmin/transport"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mode is the Telegram parse mode a Doc is rendered for.
+type Mode string
+
+const (
+	MarkdownV2 Mode = transport.ModeMarkdownV2
+	HTML       Mode = transport.ModeHTML
+)
+
+type style int
+
+const (
+	plain style = iota
+	bold
+	italic
+	code
+)
+
+// Part is a run of text with one style. Its content is always escaped when
+// rendered, so it may hold anything, including process names and paths.
+type Part struct {
+	text  string
+	style style
+}
+
+func Text(s string) Part {
+	return Part{text: s}
+}
+
+func Textf(format string, args ...any) Part {
+	return Part{text: fmt.Sprintf(format, args...)}
+}
+
+func Bold(s string) Part {
+	return Part{text: s, style: bold}
+}
+
+func Italic(s string) Part {
+	return Part{text: s, style: italic}
+}
+
+func Code(s string) Part {
+	return Part{text: s, style: code}
+}
+
+func (p Part) render(mode Mode) string {
+	if mode == HTML {
+		text := html.EscapeString(p.text)
+		switch p.style {
+		case bold:
+			return "<b>" + text + "</b>"
+		case italic:
+			return "<i>" + text + "</i>"
+		case code:
+			return "<code>" + text + "</code>"
+		}
+		return text
+	}
+
+	switch p.style {
+	case bold:
+		return "*" + escapeMarkdown(p.text) + "*"
+	case italic:
+		return "_" + escapeMarkdown(p.text) + "_"
+	case code:
+		return "`" + escapeCode(p.text) + "`"
+	}
+	return escapeMarkdown(p.text)
+}
+
+type block interface {
+	render(mode Mode) string
+}
+
+type line []Part
+
+func (l line) render(mode Mode) string {
+	var out strings.Builder
+	for _, part := range l {
+		out.WriteString(part.render(mode))
+	}
+	return out.String()
+}
+
+type pre string
+
+func (p pre) render(mode Mode) string {
+	if mode == HTML {
+		return "<pre>" + html.EscapeString(string(p)) + "</pre>"
+	}
+	return "```\n" + escapeCode(string(p)) + "\n```"
+}
+
+// Doc is a message built line by line from typed parts.
+type Doc struct {
+	blocks []block
+}
+
+func NewDoc() *Doc {
+	return &Doc{}
+}
+
+func (d *Doc) Line(parts ...Part) *Doc {
+	d.blocks = append(d.blocks, line(parts))
+	return d
+}
+
+func (d *Doc) Blank() *Doc {
+	return d.Line()
+}
+
+// Item adds a bulleted list entry.
+func (d *Doc) Item(parts ...Part) *Doc {
+	return d.Line(append([]Part{Text("• ")}, parts...)...)
+}
+
+// Field adds a bulleted "name: value" entry.
+func (d *Doc) Field(name string, value any) *Doc {
+	return d.Item(Text(name+": "), Textf("%v", value))
+}
+
+// Pre adds a monospaced block.
+func (d *Doc) Pre(text string) *Doc {
+	d.blocks = append(d.blocks, pre(text))
+	return d
+}
+
+// Table adds a monospaced block with columns padded to line up.
+func (d *Doc) Table(headers []string, rows [][]string) *Doc {
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		widths[i] = utf8.RuneCountInString(header)
+	}
+	for _, row := range rows {
+		for i := 0; i < len(row) && i < len(widths); i++ {
+			widths[i] = max(widths[i], utf8.RuneCountInString(row[i]))
+		}
+	}
+
+	var out strings.Builder
+	writeRow := func(cells []string) {
+		for i, cell := range cells {
+			if i >= len(widths) {
+				break
+			}
+			if i > 0 {
+				out.WriteString("  ")
+			}
+			out.WriteString(cell)
+			if i < len(cells)-1 {
+				out.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+			}
+		}
+		out.WriteString("\n")
+	}
+
+	writeRow(headers)
+	for _, row := range rows {
+		writeRow(row)
+	}
+
+	return d.Pre(strings.TrimSuffix(out.String(), "\n"))
+}
+
+func (d *Doc) Render(mode Mode) string {
+	lines := make([]string, len(d.blocks))
+	for i, b := range d.blocks {
+		lines[i] = b.render(mode)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Message renders the doc as MarkdownV2, ready to send.
+func (d *Doc) Message(chatID int64) transport.Message {
+	msg := transport.NewMessage(chatID, d.Render(MarkdownV2))
+	msg.ParseMode = transport.ModeMarkdownV2
+	return msg
+}
+
+// HTMLMessage renders the doc with HTML parse mode.
+func (d *Doc) HTMLMessage(chatID int64) transport.Message {
+	msg := transport.NewMessage(chatID, d.Render(HTML))
+	msg.ParseMode = transport.ModeHTML
+	return msg
+}
+
+var codeReplacer = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+
+// escapeMarkdown escapes every character MarkdownV2 treats as markup.
+func escapeMarkdown(s string) string {
+	return transport.EscapeMarkdownV2(s)
+}
+
+// escapeCode escapes the two characters that matter inside code and pre.
+func escapeCode(s string) string {
+	return codeReplacer.Replace(s)
+}
@@ -0,0 +1,59 @@
+package format
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      *Doc
+		markdown string
+		html     string
+	}{
+		{
+			name:     "styles",
+			doc:      NewDoc().Line(Bold("CPU"), Text(": "), Italic("95.5%"), Text(" "), Code("top")),
+			markdown: "*CPU*: _95\\.5%_ `top`",
+			html:     "<b>CPU</b>: <i>95.5%</i> <code>top</code>",
+		},
+		{
+			name:     "untrusted text",
+			doc:      NewDoc().Line(Text("a_b*c [x](y) <i> & `z`")),
+			markdown: "a\\_b\\*c \\[x\\]\\(y\\) <i\\> & \\`z\\`",
+			html:     "a_b*c [x](y) &lt;i&gt; &amp; `z`",
+		},
+		{
+			name:     "code escapes only backslash and backtick",
+			doc:      NewDoc().Line(Code("C:\\tmp\\a_b`c.exe")),
+			markdown: "`C:\\\\tmp\\\\a_b\\`c.exe`",
+			html:     "<code>C:\\tmp\\a_b`c.exe</code>",
+		},
+		{
+			name:     "items and fields",
+			doc:      NewDoc().Item(Text("one")).Blank().Field("PID", 42),
+			markdown: "• one\n\n• PID: 42",
+			html:     "• one\n\n• PID: 42",
+		},
+		{
+			name:     "pre",
+			doc:      NewDoc().Pre("x < 1.0"),
+			markdown: "```\nx < 1.0\n```",
+			html:     "<pre>x &lt; 1.0</pre>",
+		},
+		{
+			name:     "table",
+			doc:      NewDoc().Table([]string{"PID", "Name"}, [][]string{{"1", "init"}, {"1234", "é"}}),
+			markdown: "```\nPID   Name\n1     init\n1234  é\n```",
+			html:     "<pre>PID   Name\n1     init\n1234  é</pre>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.doc.Render(MarkdownV2); got != tt.markdown {
+				t.Errorf("MarkdownV2 = %q, want %q", got, tt.markdown)
+			}
+			if got := tt.doc.Render(HTML); got != tt.html {
+				t.Errorf("HTML = %q, want %q", got, tt.html)
+			}
+		})
+	}
+}
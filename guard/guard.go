@@ -0,0 +1,263 @@
+package guard
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"remoteadmin/config"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Attempt struct {
+	UserID   int64
+	UserName string
+	Name     string
+	Command  string
+	Time     time.Time
+}
+
+type Verdict struct {
+	Reply     bool
+	Alert     bool
+	LockedOut bool
+	Banned    bool
+	Attempts  int
+}
+
+type BlockedUser struct {
+	UserID   int64     `json:"user_id"`
+	UserName string    `json:"username"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until,omitempty"`
+}
+
+func (u BlockedUser) Permanent() bool {
+	return u.Until.IsZero()
+}
+
+type offender struct {
+	attempts []time.Time
+	lockouts int
+	seen     bool
+	last     time.Time
+}
+
+// offenderGrace is how long a stranger is remembered after their window
+// and any lockout have run out, so lockouts still add up towards a ban
+// for someone who keeps coming back.
+const offenderGrace = 24 * time.Hour
+
+// pruneInterval spaces out the sweeps for offenders to forget.
+const pruneInterval = time.Minute
+
+type Guard struct {
+	mu        sync.Mutex
+	cfg       config.UnauthorizedConfig
+	path      string
+	offenders map[int64]*offender
+	blocked   map[int64]BlockedUser
+	lastPrune time.Time
+}
+
+type blockedFile struct {
+	Banned []BlockedUser `json:"banned"`
+}
+
+func New(cfg config.UnauthorizedConfig, path string) *Guard {
+	g := &Guard{
+		cfg:       cfg,
+		path:      path,
+		offenders: make(map[int64]*offender),
+		blocked:   make(map[int64]BlockedUser),
+	}
+	g.load()
+	return g
+}
+
+func (g *Guard) IsIgnored(userID int64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.isBlocked(userID, time.Now())
+}
+
+// Record notes an unauthorized attempt and decides whether the bot should
+// still answer it and whether admins should hear about it.
+func (g *Guard) Record(attempt Attempt) Verdict {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := attempt.Time
+	g.prune(now)
+	if g.isBlocked(attempt.UserID, now) {
+		return Verdict{}
+	}
+
+	o, ok := g.offenders[attempt.UserID]
+	if !ok {
+		o = &offender{}
+		g.offenders[attempt.UserID] = o
+	}
+	o.last = now
+
+	window := time.Duration(g.cfg.WindowMinutes) * time.Minute
+	recent := o.attempts[:0]
+	for _, t := range o.attempts {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	o.attempts = append(recent, now)
+
+	verdict := Verdict{
+		Reply:    !g.cfg.Silent,
+		Attempts: len(o.attempts),
+	}
+
+	switch g.cfg.Alert {
+	case config.AlertAll:
+		verdict.Alert = true
+	case config.AlertFirst:
+		verdict.Alert = !o.seen
+	}
+	o.seen = true
+
+	if len(o.attempts) >= g.cfg.MaxAttempts {
+		o.attempts = nil
+		o.lockouts++
+
+		blocked := BlockedUser{
+			UserID:   attempt.UserID,
+			UserName: attempt.UserName,
+			Since:    now,
+		}
+
+		if g.cfg.BanAfterLockouts > 0 && o.lockouts >= g.cfg.BanAfterLockouts {
+			verdict.Banned = true
+			g.blocked[attempt.UserID] = blocked
+			g.save()
+		} else {
+			verdict.LockedOut = true
+			blocked.Until = now.Add(time.Duration(g.cfg.LockoutMinutes) * time.Minute)
+			g.blocked[attempt.UserID] = blocked
+		}
+
+		verdict.Alert = g.cfg.Alert != config.AlertNone
+	}
+
+	return verdict
+}
+
+func (g *Guard) Blocked() []BlockedUser {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	var list []BlockedUser
+	for id, user := range g.blocked {
+		if g.isBlocked(id, now) {
+			list = append(list, user)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Since.Before(list[j].Since)
+	})
+
+	return list
+}
+
+func (g *Guard) Unblock(userID int64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	user, ok := g.blocked[userID]
+	if !ok {
+		return false
+	}
+
+	delete(g.blocked, userID)
+	delete(g.offenders, userID)
+	if user.Permanent() {
+		g.save()
+	}
+
+	return true
+}
+
+// prune forgets offenders who have been quiet for longer than the window,
+// the lockout and offenderGrace together, and lockouts that have ended.
+// Permanent bans stay.
+func (g *Guard) prune(now time.Time) {
+	if now.Sub(g.lastPrune) < pruneInterval {
+		return
+	}
+	g.lastPrune = now
+
+	window := time.Duration(g.cfg.WindowMinutes) * time.Minute
+	lockout := time.Duration(g.cfg.LockoutMinutes) * time.Minute
+	for id, o := range g.offenders {
+		if _, blocked := g.blocked[id]; !blocked && now.Sub(o.last) > window+lockout+offenderGrace {
+			delete(g.offenders, id)
+		}
+	}
+	for id := range g.blocked {
+		g.isBlocked(id, now)
+	}
+}
+
+func (g *Guard) isBlocked(userID int64, now time.Time) bool {
+	user, ok := g.blocked[userID]
+	if !ok {
+		return false
+	}
+
+	if !user.Permanent() && now.After(user.Until) {
+		delete(g.blocked, userID)
+		return false
+	}
+
+	return true
+}
+
+func (g *Guard) load() {
+	data, err := os.ReadFile(g.path)
+	if err != nil {
+		return
+	}
+
+	var file blockedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		slog.Warn("failed to read banned users", "path", g.path, "error", err)
+		return
+	}
+
+	for _, user := range file.Banned {
+		user.Until = time.Time{}
+		g.blocked[user.UserID] = user
+	}
+}
+
+// save writes the permanent bans; temporary lockouts aren't kept across
+// restarts.
+func (g *Guard) save() {
+	var file blockedFile
+	for _, user := range g.blocked {
+		if user.Permanent() {
+			file.Banned = append(file.Banned, user)
+		}
+	}
+	sort.Slice(file.Banned, func(i, j int) bool {
+		return file.Banned[i].Since.Before(file.Banned[j].Since)
+	})
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err == nil {
+		err = os.WriteFile(g.path, data, 0600)
+	}
+	if err != nil {
+		slog.Warn("failed to save banned users", "path", g.path, "error", err)
+	}
+}
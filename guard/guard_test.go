@@ -0,0 +1,128 @@
+package guard
+
+import (
+	"path/filepath"
+	"remoteadmin/config"
+	"testing"
+	"time"
+)
+
+func testConfig() config.UnauthorizedConfig {
+	return config.UnauthorizedConfig{
+		Alert:          config.AlertFirst,
+		MaxAttempts:    3,
+		WindowMinutes:  10,
+		LockoutMinutes: 60,
+	}
+}
+
+func newTestGuard(t *testing.T, cfg config.UnauthorizedConfig) (*Guard, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "banned.json")
+	return New(cfg, path), path
+}
+
+func TestRecordLocksOut(t *testing.T) {
+	g, _ := newTestGuard(t, testConfig())
+	now := time.Now()
+	attempt := func(at time.Time) Verdict {
+		return g.Record(Attempt{UserID: 7, UserName: "eve", Time: at})
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want Verdict
+	}{
+		{"first attempt alerts", now, Verdict{Reply: true, Alert: true, Attempts: 1}},
+		{"second is quiet", now.Add(time.Minute), Verdict{Reply: true, Attempts: 2}},
+		{"third locks out", now.Add(2 * time.Minute), Verdict{Reply: true, Alert: true, LockedOut: true, Attempts: 3}},
+		{"ignored while locked out", now.Add(30 * time.Minute), Verdict{}},
+		{"counted again after the lockout", now.Add(3 * time.Hour), Verdict{Reply: true, Attempts: 1}},
+	}
+	for _, tt := range tests {
+		if got := attempt(tt.at); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecordWindow(t *testing.T) {
+	g, _ := newTestGuard(t, testConfig())
+	now := time.Now()
+
+	g.Record(Attempt{UserID: 7, Time: now})
+	g.Record(Attempt{UserID: 7, Time: now.Add(time.Minute)})
+	v := g.Record(Attempt{UserID: 7, Time: now.Add(20 * time.Minute)})
+	if v.LockedOut || v.Attempts != 1 {
+		t.Errorf("attempts outside the window counted: %+v", v)
+	}
+}
+
+func TestBanPersists(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxAttempts = 1
+	cfg.BanAfterLockouts = 2
+	g, path := newTestGuard(t, cfg)
+	now := time.Now()
+
+	if v := g.Record(Attempt{UserID: 7, UserName: "eve", Time: now}); !v.LockedOut {
+		t.Fatalf("first lockout: %+v", v)
+	}
+	if v := g.Record(Attempt{UserID: 7, UserName: "eve", Time: now.Add(2 * time.Hour)}); !v.Banned {
+		t.Fatalf("second lockout did not ban: %+v", v)
+	}
+
+	reloaded := New(cfg, path)
+	if !reloaded.IsIgnored(7) {
+		t.Fatal("ban was not saved")
+	}
+	blocked := reloaded.Blocked()
+	if len(blocked) != 1 || !blocked[0].Permanent() || blocked[0].UserName != "eve" {
+		t.Errorf("Blocked() = %+v", blocked)
+	}
+
+	if !reloaded.Unblock(7) || reloaded.IsIgnored(7) {
+		t.Fatal("Unblock did not lift the ban")
+	}
+	if New(cfg, path).IsIgnored(7) {
+		t.Error("Unblock was not saved")
+	}
+}
+
+func TestOffendersAreForgotten(t *testing.T) {
+	g, _ := newTestGuard(t, testConfig())
+	now := time.Now()
+
+	for id := int64(1); id <= 100; id++ {
+		g.Record(Attempt{UserID: id, Time: now})
+	}
+	if len(g.offenders) != 100 {
+		t.Fatalf("offenders = %d, want 100", len(g.offenders))
+	}
+
+	later := now.Add(10*time.Minute + 60*time.Minute + offenderGrace + time.Minute)
+	v := g.Record(Attempt{UserID: 1, Time: later})
+	if len(g.offenders) != 1 {
+		t.Errorf("offenders = %d after they went quiet, want 1", len(g.offenders))
+	}
+	if !v.Alert {
+		t.Error("a forgotten offender should alert again")
+	}
+}
+
+func TestExpiredLockoutsArePruned(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxAttempts = 1
+	g, _ := newTestGuard(t, cfg)
+	now := time.Now()
+
+	g.Record(Attempt{UserID: 1, Time: now})
+	if len(g.blocked) != 1 {
+		t.Fatalf("blocked = %d, want 1", len(g.blocked))
+	}
+	g.Record(Attempt{UserID: 2, Time: now.Add(2 * time.Hour)})
+	if _, ok := g.blocked[1]; ok {
+		t.Error("expired lockout was kept")
+	}
+}
@@ -0,0 +1,97 @@
+package guard
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter counts failures per key, such as wrong codes from one user or
+// bad logins from one address, and locks a key out once it fails
+// maxAttempts times within window. Keys with nothing to remember are
+// forgotten, so the map stays as small as the set of recent failures.
+type Limiter struct {
+	mu          sync.Mutex
+	maxAttempts int
+	window      time.Duration
+	lockout     time.Duration
+	keys        map[string]*failures
+	now         func() time.Time
+}
+
+type failures struct {
+	times []time.Time
+	until time.Time
+}
+
+func NewLimiter(maxAttempts int, window, lockout time.Duration) *Limiter {
+	return &Limiter{
+		maxAttempts: maxAttempts,
+		window:      window,
+		lockout:     lockout,
+		keys:        make(map[string]*failures),
+		now:         time.Now,
+	}
+}
+
+// Allow reports whether key may try again, and if not, how long it has to
+// wait.
+func (l *Limiter) Allow(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.keys[key]
+	if !ok {
+		return 0, true
+	}
+	if wait := f.until.Sub(l.now()); wait > 0 {
+		return wait, false
+	}
+	return 0, true
+}
+
+// Fail records a failure and reports whether it locked the key out.
+func (l *Limiter) Fail(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.pruneLocked(now)
+
+	f, ok := l.keys[key]
+	if !ok {
+		f = &failures{}
+		l.keys[key] = f
+	}
+	f.times = append(f.times, now)
+
+	if len(f.times) < l.maxAttempts {
+		return false
+	}
+	f.times = nil
+	f.until = now.Add(l.lockout)
+	return true
+}
+
+// Reset forgets key's failures, after it got something right.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.keys, key)
+}
+
+func (l *Limiter) pruneLocked(now time.Time) {
+	for key, f := range l.keys {
+		recent := f.times[:0]
+		for _, t := range f.times {
+			if now.Sub(t) < l.window {
+				recent = append(recent, t)
+			}
+		}
+		f.times = recent
+
+		if len(f.times) == 0 && !now.Before(f.until) {
+			delete(l.keys, key)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package guard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter(3, 10*time.Minute, 15*time.Minute)
+	l.now = func() time.Time { return now }
+
+	if l.Fail("a") || l.Fail("a") {
+		t.Fatal("locked out before the third failure")
+	}
+	if _, ok := l.Allow("a"); !ok {
+		t.Fatal("refused before the lockout")
+	}
+	if !l.Fail("a") {
+		t.Fatal("third failure did not lock out")
+	}
+	if wait, ok := l.Allow("a"); ok || wait != 15*time.Minute {
+		t.Fatalf("Allow = %v, %v; want 15m, false", wait, ok)
+	}
+	if _, ok := l.Allow("b"); !ok {
+		t.Fatal("other keys are locked out too")
+	}
+
+	now = now.Add(15 * time.Minute)
+	if _, ok := l.Allow("a"); !ok {
+		t.Fatal("still locked out after the lockout")
+	}
+}
+
+func TestLimiterWindow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter(2, time.Minute, time.Hour)
+	l.now = func() time.Time { return now }
+
+	l.Fail("a")
+	now = now.Add(2 * time.Minute)
+	if l.Fail("a") {
+		t.Fatal("a failure outside the window counted")
+	}
+	if len(l.keys) != 1 {
+		t.Fatalf("keys = %d, want 1", len(l.keys))
+	}
+
+	// Old failures of other keys are dropped on the next failure.
+	now = now.Add(2 * time.Minute)
+	l.Fail("b")
+	if _, ok := l.keys["a"]; ok {
+		t.Error("expired key was kept")
+	}
+}
+
+func TestLimiterReset(t *testing.T) {
+	l := NewLimiter(2, time.Minute, time.Hour)
+	l.Fail("a")
+	l.Reset("a")
+	if l.Fail("a") {
+		t.Error("failure before the reset still counted")
+	}
+}
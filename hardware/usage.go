@@ -0,0 +1,55 @@
+package hardware
+
+import (
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+type DiskUsage struct {
+	Mountpoint string
+	Total      uint64
+	Used       uint64
+}
+
+// Usage is a point-in-time reading of host load, cheap enough to take on
+// every metrics scrape.
+type Usage struct {
+	CPUPercent  float64
+	MemoryTotal uint64
+	MemoryUsed  uint64
+	Disks       []DiskUsage
+}
+
+func GetUsage() Usage {
+	var usage Usage
+
+	// With a zero interval gopsutil compares against the previous call, so
+	// the first reading after start is 0.
+	if percents, err := cpu.Percent(0, false); err == nil && len(percents) > 0 {
+		usage.CPUPercent = percents[0]
+	}
+
+	if memInfo, err := mem.VirtualMemory(); err == nil {
+		usage.MemoryTotal = memInfo.Total
+		usage.MemoryUsed = memInfo.Used
+	}
+
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return usage
+	}
+	for _, partition := range partitions {
+		diskUsage, err := disk.Usage(partition.Mountpoint)
+		if err != nil {
+			continue
+		}
+		usage.Disks = append(usage.Disks, DiskUsage{
+			Mountpoint: partition.Mountpoint,
+			Total:      diskUsage.Total,
+			Used:       diskUsage.Used,
+		})
+	}
+
+	return usage
+}
@@ -0,0 +1,187 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusDone      Status = "done"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+const maxFinishedJobs = 20
+
+type Job struct {
+	ID        int
+	Name      string
+	Owner     int64
+	OwnerName string
+	ChatID    int64
+	Created   time.Time
+	Started   time.Time
+	Finished  time.Time
+	Status    Status
+	Error     string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (j *Job) Context() context.Context {
+	return j.ctx
+}
+
+func (j *Job) IsFinished() bool {
+	return j.Status == StatusDone || j.Status == StatusFailed || j.Status == StatusCancelled
+}
+
+type Manager struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	nextID int
+	jobs   map[int]*Job
+}
+
+func NewManager() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[int]*Job),
+	}
+}
+
+func (m *Manager) Create(name string, owner int64, ownerName string, chatID int64) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	ctx, cancel := context.WithCancel(m.ctx)
+	job := &Job{
+		ID:        m.nextID,
+		Name:      name,
+		Owner:     owner,
+		OwnerName: ownerName,
+		ChatID:    chatID,
+		Created:   time.Now(),
+		Status:    StatusQueued,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	m.jobs[job.ID] = job
+
+	return job
+}
+
+// Begin marks a queued job as running. It returns false if the job was
+// cancelled while it was still waiting in the queue.
+func (m *Manager) Begin(job *Job) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job.Status != StatusQueued {
+		return false
+	}
+
+	job.Status = StatusRunning
+	job.Started = time.Now()
+	return true
+}
+
+func (m *Manager) Finish(job *Job, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job.Status == StatusCancelled {
+		job.cancel()
+		return
+	}
+
+	switch {
+	case job.ctx.Err() != nil:
+		job.Status = StatusCancelled
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	default:
+		job.Status = StatusDone
+	}
+	job.Finished = time.Now()
+	job.cancel()
+
+	m.prune()
+}
+
+func (m *Manager) Cancel(id int) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job %d not found", id)
+	}
+
+	switch job.Status {
+	case StatusQueued:
+		job.Status = StatusCancelled
+		job.Finished = time.Now()
+		job.cancel()
+	case StatusRunning:
+		job.cancel()
+	default:
+		return job, fmt.Errorf("job %d already %s", id, job.Status)
+	}
+
+	return job, nil
+}
+
+func (m *Manager) CancelAll() {
+	m.cancel()
+}
+
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		list = append(list, *job)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+
+	return list
+}
+
+func (m *Manager) prune() {
+	var finished []*Job
+	for _, job := range m.jobs {
+		if job.IsFinished() {
+			finished = append(finished, job)
+		}
+	}
+
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].ID < finished[j].ID
+	})
+
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, job.ID)
+	}
+}
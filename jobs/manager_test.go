@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJobLifecycle(t *testing.T) {
+	tests := []struct {
+		name       string
+		begin      bool
+		cancel     bool
+		shutdown   bool
+		err        error
+		wantStatus Status
+		wantError  string
+	}{
+		{name: "done", begin: true, wantStatus: StatusDone},
+		{name: "failed", begin: true, err: errors.New("ffmpeg exited"), wantStatus: StatusFailed, wantError: "ffmpeg exited"},
+		{name: "cancelled while running", begin: true, cancel: true, wantStatus: StatusCancelled},
+		{name: "cancelled while queued", cancel: true, wantStatus: StatusCancelled},
+		{name: "shutdown", begin: true, shutdown: true, wantStatus: StatusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager()
+			job := m.Create("vid", 1, "alice", 1)
+			if tt.begin {
+				m.Begin(job)
+			}
+			if tt.cancel {
+				m.Cancel(job.ID)
+			}
+			if tt.shutdown {
+				m.CancelAll()
+			}
+			m.Finish(job, tt.err)
+
+			if job.Status != tt.wantStatus || job.Error != tt.wantError {
+				t.Errorf("status %s %q, want %s %q", job.Status, job.Error, tt.wantStatus, tt.wantError)
+			}
+			if job.Context().Err() == nil {
+				t.Error("context still live after the job finished")
+			}
+		})
+	}
+}
+
+func TestCancelledJobNeverBegins(t *testing.T) {
+	m := NewManager()
+	job := m.Create("vid", 1, "alice", 1)
+	m.Cancel(job.ID)
+
+	if m.Begin(job) {
+		t.Error("began a cancelled job")
+	}
+	if _, err := m.Cancel(job.ID); err == nil {
+		t.Error("cancelled a job twice")
+	}
+	if _, err := m.Cancel(99); err == nil {
+		t.Error("cancelled a job that doesn't exist")
+	}
+}
+
+func TestFinishedJobsArePruned(t *testing.T) {
+	m := NewManager()
+	for i := 0; i < maxFinishedJobs+5; i++ {
+		job := m.Create("vid", 1, "alice", 1)
+		m.Begin(job)
+		m.Finish(job, nil)
+	}
+	running := m.Create("audio", 1, "alice", 1)
+	m.Begin(running)
+
+	list := m.List()
+	if len(list) != maxFinishedJobs+1 {
+		t.Fatalf("kept %d jobs, want %d", len(list), maxFinishedJobs+1)
+	}
+	if list[0].ID != 6 || list[len(list)-1].ID != running.ID {
+		t.Errorf("kept jobs %d to %d, want the newest", list[0].ID, list[len(list)-1].ID)
+	}
+}
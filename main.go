@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
 	"remoteadmin/ascii"
 	"remoteadmin/bot"
 	"remoteadmin/config"
 	"remoteadmin/console"
+	"remoteadmin/logging"
+	"syscall"
 )
 
 func main() {
@@ -23,6 +29,14 @@ func main() {
 		log.Fatal("Invalid configuration:", err)
 	}
 
+	logFile, err := logging.Setup(cfg.Log)
+	if err != nil {
+		log.Fatal("Failed to set up logging:", err)
+	}
+	defer logFile.Close()
+
+	slog.Info("bot starting", "mode", cfg.Mode, "pid", os.Getpid())
+
 	telegramBot, err := bot.NewBot(cfg)
 	if err != nil {
 		log.Fatal("Failed to create bot:", err)
@@ -39,7 +53,18 @@ func main() {
 	consoleHandler.ShowCommands()
 	fmt.Println()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		telegramBot.Shutdown()
+	}()
+
 	if err := telegramBot.Start(); err != nil {
+		telegramBot.Shutdown()
 		log.Fatal("Bot error:", err)
 	}
+
+	telegramBot.Shutdown()
 }
@@ -0,0 +1,17 @@
+package metrics
+
+// Default holds the bot's own metrics and is what /metrics serves.
+var Default = NewRegistry()
+
+var (
+	commandBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+	ffmpegBuckets  = []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+)
+
+var (
+	Commands        = Default.NewCounterVec("remoteadmin_commands_total", "Commands handled, by outcome.", "command", "outcome")
+	CommandDuration = Default.NewHistogramVec("remoteadmin_command_duration_seconds", "Time spent running a command.", commandBuckets, "command")
+	SendFailures    = Default.NewCounterVec("remoteadmin_send_failures_total", "Telegram sends that failed for good.", "kind")
+	BrowserKills    = Default.NewCounterVec("remoteadmin_browser_kills_total", "Browser processes killed for showing a banned site.", "browser")
+	FFmpegDuration  = Default.NewHistogramVec("remoteadmin_ffmpeg_duration_seconds", "FFmpeg run time.", ffmpegBuckets, "task", "result")
+)
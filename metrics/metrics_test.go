@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+
+	commands := r.NewCounterVec("commands_total", "Commands run.", "command", "outcome")
+	commands.Inc("ss", "ok")
+	commands.Inc("ss", "ok")
+	commands.Add(0.5, "info", `say "hi"`+"\n")
+
+	duration := r.NewHistogramVec("duration_seconds", "How long it took.\nIn seconds.", []float64{1, 0.1}, "task")
+	duration.Observe(0.05, "a")
+	duration.Observe(0.5, "a")
+	duration.Observe(3, "a")
+
+	r.NewGaugeFunc("queue_depth", "Waiting jobs.", func() []Sample {
+		return []Sample{{Value: 3}}
+	})
+	r.NewGaugeFunc("empty", "Never reported.", func() []Sample { return nil })
+
+	var out strings.Builder
+	r.WriteText(&out)
+
+	want := `# HELP commands_total Commands run.
+# TYPE commands_total counter
+commands_total{command="info",outcome="say \"hi\"\n"} 0.5
+commands_total{command="ss",outcome="ok"} 2
+# HELP duration_seconds How long it took.\nIn seconds.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{task="a",le="0.1"} 1
+duration_seconds_bucket{task="a",le="1"} 2
+duration_seconds_bucket{task="a",le="+Inf"} 3
+duration_seconds_sum{task="a"} 3.55
+duration_seconds_count{task="a"} 3
+# HELP queue_depth Waiting jobs.
+# TYPE queue_depth gauge
+queue_depth 3
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+	NewRegistry().NewCounterVec("c", "help", "a").Inc()
+}
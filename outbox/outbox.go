@@ -0,0 +1,324 @@
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"remoteadmin/transport"
+	"sync"
+	"time"
+)
+
+const (
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = 5 * time.Minute
+)
+
+// Note is a notification to queue. Notes with the same Key (or, without
+// one, the same Text) that are still waiting for the same chat collapse
+// into one entry; Summary is then used with the count, e.g.
+// "%d browser blocks since the last notice went out". Notes collapse
+// whenever one is still queued, which includes a rate limit backoff, not
+// only an outage.
+type Note struct {
+	Key     string
+	Text    string
+	Summary string
+}
+
+// Entry is one queued notification, possibly standing for several notes.
+type Entry struct {
+	ChatID  int64     `json:"chat_id"`
+	Key     string    `json:"key"`
+	Text    string    `json:"text"`
+	Summary string    `json:"summary,omitempty"`
+	Count   int       `json:"count"`
+	First   time.Time `json:"first"`
+	Last    time.Time `json:"last"`
+}
+
+// Message is the text to send: the note itself, or a summary when it
+// stands for several.
+func (e *Entry) Message() string {
+	if e.Count <= 1 {
+		return e.Text
+	}
+
+	span := fmt.Sprintf("between %s and %s", e.First.Format("Jan 2 15:04"), e.Last.Format("Jan 2 15:04"))
+	if e.Summary != "" {
+		return fmt.Sprintf(e.Summary, e.Count) + " (" + span + ").\nLatest: " + e.Text
+	}
+	return fmt.Sprintf("%s\n\n(%d times %s)", e.Text, e.Count, span)
+}
+
+// Outbox keeps notifications on disk until they are delivered, so nothing
+// is lost while Telegram or the coordinator can't be reached. Entries go
+// out in the order they were queued and are dropped once older than
+// maxAge.
+type Outbox struct {
+	messenger transport.Messenger
+	path      string
+	maxAge    time.Duration
+
+	mu      sync.Mutex
+	entries []*Entry
+	sending *Entry
+	started bool
+	// failing is set while the sender waits to retry a failed send, so
+	// new notes don't cut the backoff short.
+	failing bool
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func New(messenger transport.Messenger, path string, maxAge time.Duration) *Outbox {
+	o := &Outbox{
+		messenger: messenger,
+		path:      path,
+		maxAge:    maxAge,
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	o.load()
+	return o
+}
+
+// Start delivers queued notifications in the background until Close.
+func (o *Outbox) Start() {
+	o.mu.Lock()
+	o.started = true
+	o.mu.Unlock()
+
+	go o.run()
+	o.signal()
+}
+
+// Add queues a note for chatID.
+func (o *Outbox) Add(chatID int64, note Note) {
+	key := note.Key
+	if key == "" {
+		key = note.Text
+	}
+	now := time.Now()
+
+	o.mu.Lock()
+	o.dropExpiredLocked(now)
+
+	collapsed := false
+	for _, e := range o.entries {
+		// The entry being sent can't take more notes; they'd be lost
+		// when it is removed.
+		if e != o.sending && e.ChatID == chatID && e.Key == key {
+			e.Count++
+			e.Text = note.Text
+			e.Last = now
+			collapsed = true
+			break
+		}
+	}
+	if !collapsed {
+		o.entries = append(o.entries, &Entry{
+			ChatID:  chatID,
+			Key:     key,
+			Text:    note.Text,
+			Summary: note.Summary,
+			Count:   1,
+			First:   now,
+			Last:    now,
+		})
+	}
+	o.saveLocked()
+	failing := o.failing
+	o.mu.Unlock()
+
+	if !failing {
+		o.signal()
+	}
+}
+
+// Pending returns how many entries are waiting.
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// Close gives queued notifications up to timeout to go out, then stops.
+// Whatever is left stays on disk for the next start.
+func (o *Outbox) Close(timeout time.Duration) {
+	o.mu.Lock()
+	started := o.started
+	o.mu.Unlock()
+	if !started {
+		return
+	}
+
+	o.signal()
+	deadline := time.Now().Add(timeout)
+	for o.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	close(o.stop)
+	select {
+	case <-o.done:
+	case <-time.After(time.Until(deadline)):
+	}
+
+	if n := o.Pending(); n > 0 {
+		slog.Info("notifications kept for the next start", "pending", n, "path", o.path)
+	}
+}
+
+func (o *Outbox) signal() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *Outbox) run() {
+	defer close(o.done)
+
+	delay := minRetryDelay
+	for {
+		var retry <-chan time.Time
+		if !o.deliver() {
+			retry = time.After(delay)
+			delay = min(delay*2, maxRetryDelay)
+			// A note added before the send failed may have left a
+			// wake-up behind.
+			select {
+			case <-o.wake:
+			default:
+			}
+		} else {
+			delay = minRetryDelay
+		}
+
+		select {
+		case <-o.stop:
+			return
+		case <-o.wake:
+		case <-retry:
+		}
+	}
+}
+
+// deliver sends entries oldest first until the queue is empty or a send
+// fails. It reports whether the queue was emptied.
+func (o *Outbox) deliver() bool {
+	for {
+		select {
+		case <-o.stop:
+			return false
+		default:
+		}
+
+		o.mu.Lock()
+		o.dropExpiredLocked(time.Now())
+		if len(o.entries) == 0 {
+			o.failing = false
+			o.mu.Unlock()
+			return true
+		}
+		e := o.entries[0]
+		o.sending = e
+		text := e.Message()
+		o.mu.Unlock()
+
+		msg := transport.NewMessage(e.ChatID, text)
+		_, err := o.messenger.Send(msg)
+
+		o.mu.Lock()
+		o.sending = nil
+		if err != nil && !transport.IsPermanent(err) {
+			o.failing = true
+			o.mu.Unlock()
+			slog.Debug("notification not delivered, will retry", "chat_id", e.ChatID, "error", err)
+			return false
+		}
+		if err != nil {
+			slog.Warn("dropping notification that cannot be delivered", "chat_id", e.ChatID, "error", err)
+		}
+		o.removeLocked(e)
+		o.saveLocked()
+		o.mu.Unlock()
+	}
+}
+
+func (o *Outbox) removeLocked(target *Entry) {
+	for i, e := range o.entries {
+		if e == target {
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (o *Outbox) dropExpiredLocked(now time.Time) {
+	kept := o.entries[:0]
+	dropped := 0
+	for _, e := range o.entries {
+		if e != o.sending && now.Sub(e.Last) > o.maxAge {
+			dropped++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	o.entries = kept
+
+	if dropped > 0 {
+		slog.Info("dropped expired notifications", "count", dropped, "max_age", o.maxAge)
+		o.saveLocked()
+	}
+}
+
+func (o *Outbox) load() {
+	data, err := os.ReadFile(o.path)
+	if err != nil {
+		return
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		slog.Warn("failed to read outbox", "path", o.path, "error", err)
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries = entries
+	o.dropExpiredLocked(time.Now())
+	if len(o.entries) > 0 {
+		slog.Info("notifications waiting from the last run", "pending", len(o.entries))
+	}
+}
+
+// saveLocked replaces the file in one step so a crash never leaves it
+// half written.
+func (o *Outbox) saveLocked() {
+	entries := o.entries
+	if entries == nil {
+		entries = []*Entry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		slog.Warn("failed to encode outbox", "error", err)
+		return
+	}
+
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		slog.Warn("failed to save outbox", "path", o.path, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, o.path); err != nil {
+		slog.Warn("failed to save outbox", "path", o.path, "error", err)
+	}
+}
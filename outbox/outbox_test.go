@@ -0,0 +1,147 @@
+package outbox
+
+import (
+	"errors"
+	"path/filepath"
+	"remoteadmin/transport"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingMessenger refuses every message with err.
+type failingMessenger struct {
+	*transport.Recorder
+	err      error
+	attempts atomic.Int32
+}
+
+func (m *failingMessenger) Send(msg transport.Message) (int, error) {
+	m.attempts.Add(1)
+	return 0, m.err
+}
+
+func (o *Outbox) isFailing() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.failing
+}
+
+func TestEntryMessage(t *testing.T) {
+	first := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	last := first.Add(90 * time.Minute)
+
+	tests := []struct {
+		name  string
+		entry Entry
+		want  string
+	}{
+		{"single", Entry{Text: "blocked", Count: 1}, "blocked"},
+		{
+			"summary",
+			Entry{Text: "blocked", Summary: "%d blocks", Count: 3, First: first, Last: last},
+			"3 blocks (between Mar 1 09:00 and Mar 1 10:30).\nLatest: blocked",
+		},
+		{
+			"no summary",
+			Entry{Text: "blocked", Count: 2, First: first, Last: last},
+			"blocked\n\n(2 times between Mar 1 09:00 and Mar 1 10:30)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.Message(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutboxCollapsesAndPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	o := New(transport.NewRecorder(), path, time.Hour)
+
+	o.Add(1, Note{Key: "block", Text: "a", Summary: "%d blocks"})
+	o.Add(1, Note{Key: "block", Text: "b", Summary: "%d blocks"})
+	o.Add(2, Note{Key: "block", Text: "c", Summary: "%d blocks"})
+	o.Add(1, Note{Text: "shutdown"})
+	if n := o.Pending(); n != 3 {
+		t.Fatalf("pending %d, want 3", n)
+	}
+
+	// Every note is on disk as soon as Add returns.
+	reloaded := New(transport.NewRecorder(), path, time.Hour)
+	if n := reloaded.Pending(); n != 3 {
+		t.Fatalf("reloaded %d entries, want 3", n)
+	}
+	if e := reloaded.entries[0]; e.Count != 2 || e.Text != "b" {
+		t.Errorf("first entry = %d × %q, want 2 × \"b\"", e.Count, e.Text)
+	}
+}
+
+func TestOutboxDropsExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	o := New(transport.NewRecorder(), path, time.Hour)
+	o.Add(1, Note{Text: "old"})
+	o.entries[0].Last = time.Now().Add(-2 * time.Hour)
+
+	o.Add(1, Note{Text: "new"})
+	if n := o.Pending(); n != 1 {
+		t.Errorf("pending %d, want 1", n)
+	}
+}
+
+func TestOutboxDelivery(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		pending int
+	}{
+		{"sent", nil, 0},
+		{"kept after a failure", errors.New("offline"), 1},
+		{"dropped when undeliverable", &transport.SendError{Err: errors.New("blocked"), Permanent: true}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := transport.NewRecorder()
+			var messenger transport.Messenger = recorder
+			if tt.err != nil {
+				messenger = &failingMessenger{Recorder: recorder, err: tt.err}
+			}
+
+			o := New(messenger, filepath.Join(t.TempDir(), "outbox.json"), time.Hour)
+			o.Start()
+			o.Add(1, Note{Text: "hello"})
+			o.Close(200 * time.Millisecond)
+
+			if n := o.Pending(); n != tt.pending {
+				t.Errorf("pending %d, want %d", n, tt.pending)
+			}
+			if tt.err == nil && len(recorder.Messages()) != 1 {
+				t.Errorf("sent %d messages, want 1", len(recorder.Messages()))
+			}
+		})
+	}
+}
+
+func TestOutboxAddKeepsBackoff(t *testing.T) {
+	messenger := &failingMessenger{Recorder: transport.NewRecorder(), err: errors.New("offline")}
+	o := New(messenger, filepath.Join(t.TempDir(), "outbox.json"), time.Hour)
+	o.Start()
+	defer o.Close(0)
+
+	o.Add(1, Note{Text: "first"})
+	deadline := time.Now().Add(time.Second)
+	for !o.isFailing() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for range 5 {
+		o.Add(1, Note{Text: "more"})
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if n := messenger.attempts.Load(); n != 1 {
+		t.Errorf("%d send attempts during the backoff, want 1", n)
+	}
+}
@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+var ErrClosed = errors.New("queue is closed")
+
+type task struct {
+	run   func()
+	heavy bool
+}
+
+type Pool struct {
+	mu          sync.Mutex
+	cond        *sync.Cond
+	pending     map[int64][]task
+	active      map[int64]bool
+	ready       []int64
+	waiting     []int64
+	heavyLimit  int
+	heavyActive int
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+func NewPool(workers, heavyLimit int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if heavyLimit < 1 {
+		heavyLimit = 1
+	}
+
+	p := &Pool{
+		pending:    make(map[int64][]task),
+		active:     make(map[int64]bool),
+		heavyLimit: heavyLimit,
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+
+	return p
+}
+
+// Submit queues fn behind any earlier work for the same key. Heavy tasks
+// additionally share the pool-wide heavy limit.
+func (p *Pool) Submit(key int64, heavy bool, fn func()) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrClosed
+	}
+
+	p.pending[key] = append(p.pending[key], task{run: fn, heavy: heavy})
+	if !p.active[key] {
+		p.active[key] = true
+		p.ready = append(p.ready, key)
+		p.cond.Signal()
+	}
+
+	return nil
+}
+
+func (p *Pool) Pending(key int64) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.pending[key])
+}
+
+// Close stops accepting work and waits for everything already queued.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		for len(p.ready) == 0 && !(p.closed && p.idle()) {
+			p.cond.Wait()
+		}
+		if len(p.ready) == 0 {
+			p.mu.Unlock()
+			return
+		}
+
+		key := p.ready[0]
+		p.ready = p.ready[1:]
+		next := p.pending[key][0]
+
+		if next.heavy {
+			if p.heavyActive >= p.heavyLimit {
+				p.waiting = append(p.waiting, key)
+				p.mu.Unlock()
+				continue
+			}
+			p.heavyActive++
+		}
+
+		p.pending[key] = p.pending[key][1:]
+		p.mu.Unlock()
+
+		runTask(next)
+
+		p.mu.Lock()
+		if next.heavy {
+			p.heavyActive--
+			if len(p.waiting) > 0 {
+				p.ready = append(p.ready, p.waiting[0])
+				p.waiting = p.waiting[1:]
+			}
+		}
+
+		if len(p.pending[key]) > 0 {
+			p.ready = append(p.ready, key)
+		} else {
+			delete(p.pending, key)
+			delete(p.active, key)
+		}
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	}
+}
+
+func (p *Pool) idle() bool {
+	return len(p.active) == 0
+}
+
+func runTask(t task) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("command panicked", "panic", r)
+		}
+	}()
+
+	t.run()
+}
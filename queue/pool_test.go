@@ -0,0 +1,72 @@
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolKeepsPerKeyOrder(t *testing.T) {
+	p := NewPool(4, 4)
+
+	var mu sync.Mutex
+	got := make(map[int64][]int)
+	for i := 0; i < 50; i++ {
+		key := int64(i % 3)
+		p.Submit(key, false, func() {
+			mu.Lock()
+			got[key] = append(got[key], i)
+			mu.Unlock()
+		})
+	}
+	p.Close()
+
+	for key, order := range got {
+		for j := 1; j < len(order); j++ {
+			if order[j] < order[j-1] {
+				t.Fatalf("key %d ran out of order: %v", key, order)
+			}
+		}
+	}
+}
+
+func TestPoolHeavyLimit(t *testing.T) {
+	p := NewPool(4, 1)
+
+	var running, most atomic.Int32
+	for key := int64(0); key < 4; key++ {
+		p.Submit(key, true, func() {
+			n := running.Add(1)
+			for {
+				m := most.Load()
+				if n <= m || most.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+		})
+	}
+	p.Close()
+
+	if most.Load() != 1 {
+		t.Errorf("%d heavy tasks ran at once, want 1", most.Load())
+	}
+}
+
+func TestPoolSurvivesPanics(t *testing.T) {
+	p := NewPool(1, 1)
+
+	ran := false
+	p.Submit(1, false, func() { panic("boom") })
+	p.Submit(1, false, func() { ran = true })
+	p.Close()
+
+	if !ran {
+		t.Error("task after a panic never ran")
+	}
+	if err := p.Submit(1, false, func() {}); err != ErrClosed {
+		t.Errorf("Submit after Close = %v, want ErrClosed", err)
+	}
+}
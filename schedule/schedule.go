@@ -0,0 +1,264 @@
+package schedule
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"remoteadmin/config"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Schedule runs Command as the user who created it and posts the output
+// to ChatID.
+type Schedule struct {
+	ID       int       `json:"id"`
+	Spec     string    `json:"spec"`
+	Command  string    `json:"command"`
+	ChatID   int64     `json:"chat_id"`
+	UserID   int64     `json:"user_id"`
+	UserName string    `json:"user_name"`
+	Created  time.Time `json:"created"`
+	LastRun  time.Time `json:"last_run,omitempty"`
+
+	spec *Spec
+	// checked is the last time the schedule was looked at; only runs due
+	// after it are started.
+	checked time.Time
+}
+
+// Next returns when the schedule runs next.
+func (s Schedule) Next() time.Time {
+	return s.spec.Next(s.checked)
+}
+
+type schedulesFile struct {
+	NextID    int        `json:"next_id"`
+	Schedules []Schedule `json:"schedules"`
+}
+
+// Scheduler keeps schedules in a JSON file and starts each one when its
+// cron expression matches the current minute.
+type Scheduler struct {
+	mu        sync.Mutex
+	path      string
+	catchUp   bool
+	nextID    int
+	schedules map[int]*Schedule
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func New(cfg config.SchedulesConfig) *Scheduler {
+	s := &Scheduler{
+		path:      cfg.File,
+		catchUp:   cfg.MissedRuns == config.MissedRunsCatchUp,
+		nextID:    1,
+		schedules: make(map[int]*Schedule),
+	}
+	s.load()
+	return s
+}
+
+// Start begins checking schedules once a minute and calls run in a new
+// goroutine for each one that is due. Runs missed while the bot was down
+// are dropped, or started once now when catching up.
+func (s *Scheduler) Start(run func(Schedule)) {
+	s.mu.Lock()
+	now := time.Now()
+	var missed []Schedule
+	for _, sched := range s.schedules {
+		last := sched.LastRun
+		if last.IsZero() {
+			last = sched.Created
+		}
+		next := sched.spec.Next(last)
+		if s.catchUp && !next.IsZero() && !next.After(now) {
+			sched.LastRun = now
+			missed = append(missed, *sched)
+		}
+		sched.checked = now
+	}
+	if len(missed) > 0 {
+		if err := s.saveLocked(); err != nil {
+			slog.Warn("failed to save schedules", "error", err)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sched := range missed {
+		slog.Info("catching up missed schedule", "id", sched.ID, "command", sched.Command)
+		go run(sched)
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.loop(run)
+}
+
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Scheduler) loop(run func(Schedule)) {
+	defer close(s.done)
+
+	for {
+		// Wake just after each minute starts.
+		now := time.Now()
+		wait := now.Truncate(time.Minute).Add(time.Minute).Sub(now) + 100*time.Millisecond
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(wait):
+		}
+
+		for _, sched := range s.due(time.Now()) {
+			go run(sched)
+		}
+	}
+}
+
+func (s *Scheduler) due(now time.Time) []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []Schedule
+	for _, sched := range s.schedules {
+		next := sched.spec.Next(sched.checked)
+		sched.checked = now
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+		sched.LastRun = now
+		due = append(due, *sched)
+	}
+
+	if len(due) > 0 {
+		if err := s.saveLocked(); err != nil {
+			slog.Warn("failed to save schedules", "error", err)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due
+}
+
+// Add saves a new schedule and returns it with its ID. The first run is
+// the first match after now.
+func (s *Scheduler) Add(sched Schedule) (Schedule, error) {
+	spec, err := Parse(sched.Spec)
+	if err != nil {
+		return Schedule{}, err
+	}
+	now := time.Now()
+	if spec.Next(now).IsZero() {
+		return Schedule{}, fmt.Errorf("%q never matches a real date", sched.Spec)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched.ID = s.nextID
+	sched.Created = now
+	sched.LastRun = time.Time{}
+	sched.spec = spec
+	sched.checked = now
+	s.nextID++
+	s.schedules[sched.ID] = &sched
+
+	if err := s.saveLocked(); err != nil {
+		delete(s.schedules, sched.ID)
+		return Schedule{}, fmt.Errorf("save schedules: %w", err)
+	}
+	return sched, nil
+}
+
+// Remove deletes a schedule and returns what it was.
+func (s *Scheduler) Remove(id int) (Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched, ok := s.schedules[id]
+	if !ok {
+		return Schedule{}, false
+	}
+	delete(s.schedules, id)
+	if err := s.saveLocked(); err != nil {
+		slog.Warn("failed to save schedules", "error", err)
+	}
+	return *sched, true
+}
+
+func (s *Scheduler) Get(id int) (Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched, ok := s.schedules[id]
+	if !ok {
+		return Schedule{}, false
+	}
+	return *sched, true
+}
+
+// List returns all schedules ordered by ID.
+func (s *Scheduler) List() []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Schedule, 0, len(s.schedules))
+	for _, sched := range s.schedules {
+		list = append(list, *sched)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (s *Scheduler) load() {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+
+	var file schedulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		slog.Warn("failed to read schedules", "path", s.path, "error", err)
+		return
+	}
+
+	now := time.Now()
+	for _, sched := range file.Schedules {
+		spec, err := Parse(sched.Spec)
+		if err != nil {
+			slog.Warn("dropping schedule with a bad cron expression", "id", sched.ID, "spec", sched.Spec, "error", err)
+			continue
+		}
+		sched.spec = spec
+		sched.checked = now
+		s.schedules[sched.ID] = &sched
+		s.nextID = max(s.nextID, sched.ID+1)
+	}
+	s.nextID = max(s.nextID, file.NextID)
+}
+
+func (s *Scheduler) saveLocked() error {
+	file := schedulesFile{NextID: s.nextID}
+	for _, sched := range s.schedules {
+		file.Schedules = append(file.Schedules, *sched)
+	}
+	sort.Slice(file.Schedules, func(i, j int) bool { return file.Schedules[i].ID < file.Schedules[j].ID })
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, data, 0600)
+}
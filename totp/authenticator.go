@@ -0,0 +1,169 @@
+package totp
+
+import (
+	"errors"
+	"log/slog"
+	"remoteadmin/config"
+	"remoteadmin/guard"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidCode     = errors.New("invalid or already used code")
+	ErrNotEnrolled     = errors.New("two-factor authentication is not enabled")
+	ErrAlreadyEnrolled = errors.New("two-factor authentication is already enabled")
+	ErrNoEnrollment    = errors.New("no enrollment in progress")
+	ErrLockedOut       = errors.New("too many wrong codes, try again later")
+)
+
+// Authenticator tracks enrolled users, recently verified sessions and
+// enrollments waiting for their first code. Users who keep sending wrong
+// codes are locked out for a while, so codes can't be guessed.
+type Authenticator struct {
+	mu       sync.Mutex
+	config   *config.Config
+	pending  map[int64]string
+	lastStep map[int64]int64
+	verified map[int64]time.Time
+	failures *guard.Limiter
+}
+
+func NewAuthenticator(cfg *config.Config) *Authenticator {
+	lockout := cfg.TwoFactorLockout()
+	return &Authenticator{
+		config:   cfg,
+		pending:  make(map[int64]string),
+		lastStep: make(map[int64]int64),
+		verified: make(map[int64]time.Time),
+		failures: guard.NewLimiter(cfg.TwoFactor.MaxAttempts, lockout, lockout),
+	}
+}
+
+func (a *Authenticator) Enrolled(userID int64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, ok := a.config.TwoFactor.Secrets[userID]
+	return ok
+}
+
+// Required reports whether command needs a fresh code from this user right
+// now. Users who never enrolled are not asked.
+func (a *Authenticator) Required(userID int64, command string) bool {
+	if !a.config.IsSensitive(command) {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.config.TwoFactor.Secrets[userID]; !ok {
+		return false
+	}
+	return time.Since(a.verified[userID]) > a.config.TwoFactorGrace()
+}
+
+// Verify checks a code and opens the grace window on success.
+func (a *Authenticator) Verify(userID int64, code string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	secret, ok := a.config.TwoFactor.Secrets[userID]
+	if !ok {
+		return ErrNotEnrolled
+	}
+	if err := a.accept(userID, secret, code); err != nil {
+		return err
+	}
+
+	a.verified[userID] = time.Now()
+	return nil
+}
+
+// BeginEnrollment creates a secret that only takes effect once the user
+// proves their app produces matching codes.
+func (a *Authenticator) BeginEnrollment(userID int64) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.config.TwoFactor.Secrets[userID]; ok {
+		return "", ErrAlreadyEnrolled
+	}
+
+	secret, err := NewSecret()
+	if err != nil {
+		return "", err
+	}
+	a.pending[userID] = secret
+	return secret, nil
+}
+
+func (a *Authenticator) ConfirmEnrollment(userID int64, code string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	secret, ok := a.pending[userID]
+	if !ok {
+		return ErrNoEnrollment
+	}
+	if err := a.accept(userID, secret, code); err != nil {
+		return err
+	}
+
+	a.config.TwoFactor.Secrets[userID] = secret
+	if err := a.config.SaveTwoFactor(); err != nil {
+		delete(a.config.TwoFactor.Secrets, userID)
+		return err
+	}
+
+	delete(a.pending, userID)
+	a.verified[userID] = time.Now()
+	return nil
+}
+
+func (a *Authenticator) Disable(userID int64, code string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	secret, ok := a.config.TwoFactor.Secrets[userID]
+	if !ok {
+		return ErrNotEnrolled
+	}
+	if err := a.accept(userID, secret, code); err != nil {
+		return err
+	}
+
+	delete(a.config.TwoFactor.Secrets, userID)
+	if err := a.config.SaveTwoFactor(); err != nil {
+		a.config.TwoFactor.Secrets[userID] = secret
+		return err
+	}
+
+	delete(a.verified, userID)
+	return nil
+}
+
+// accept validates code and remembers its time step so an intercepted code
+// can't be replayed. Wrong codes count towards a lockout; while it lasts
+// no code is checked at all.
+func (a *Authenticator) accept(userID int64, secret, code string) error {
+	key := strconv.FormatInt(userID, 10)
+	if _, ok := a.failures.Allow(key); !ok {
+		return ErrLockedOut
+	}
+
+	step, ok := Validate(secret, code, time.Now())
+	if !ok || step <= a.lastStep[userID] {
+		if a.failures.Fail(key) {
+			slog.Warn("two-factor locked after too many wrong codes", "user_id", userID, "lockout", a.config.TwoFactorLockout())
+			return ErrLockedOut
+		}
+		return ErrInvalidCode
+	}
+
+	a.failures.Reset(key)
+	a.lastStep[userID] = step
+	return nil
+}
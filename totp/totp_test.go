@@ -0,0 +1,106 @@
+package totp
+
+import (
+	"errors"
+	"remoteadmin/config"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code := func(t time.Time) string {
+		c, _ := Code(rfcSecret, t)
+		return c
+	}
+
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+	}{
+		{"current step", code(now), true},
+		{"previous step", code(now.Add(-period * time.Second)), true},
+		{"next step", code(now.Add(period * time.Second)), true},
+		{"two steps old", code(now.Add(-2 * period * time.Second)), false},
+		{"surrounding spaces", " " + code(now) + " ", true},
+		{"too short", code(now)[:5], false},
+		{"wrong", "000000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(rfcSecret, tt.code, now); ok != tt.ok {
+				t.Errorf("Validate(%q) = %v, want %v", tt.code, ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestVerifyLocksOut(t *testing.T) {
+	cfg := &config.Config{TwoFactor: config.TwoFactorConfig{
+		MaxAttempts:    3,
+		LockoutMinutes: 15,
+		GraceMinutes:   5,
+		Secrets:        map[int64]string{1: rfcSecret, 2: rfcSecret},
+	}}
+	auth := NewAuthenticator(cfg)
+
+	for i := 1; i < 3; i++ {
+		if err := auth.Verify(1, "000000"); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("wrong code %d: got %v, want ErrInvalidCode", i, err)
+		}
+	}
+	if err := auth.Verify(1, "000000"); !errors.Is(err, ErrLockedOut) {
+		t.Fatalf("third wrong code: got %v, want ErrLockedOut", err)
+	}
+
+	good, _ := Code(rfcSecret, time.Now())
+	if err := auth.Verify(1, good); !errors.Is(err, ErrLockedOut) {
+		t.Errorf("good code while locked out: got %v, want ErrLockedOut", err)
+	}
+	if err := auth.Verify(2, good); err != nil {
+		t.Errorf("another user was locked out too: %v", err)
+	}
+}
+
+func TestVerifyRefusesReplay(t *testing.T) {
+	cfg := &config.Config{TwoFactor: config.TwoFactorConfig{
+		MaxAttempts:    5,
+		LockoutMinutes: 15,
+		Secrets:        map[int64]string{1: rfcSecret},
+	}}
+	auth := NewAuthenticator(cfg)
+
+	good, _ := Code(rfcSecret, time.Now())
+	if err := auth.Verify(1, good); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.Verify(1, good); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("replayed code: got %v, want ErrInvalidCode", err)
+	}
+}
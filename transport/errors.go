@@ -0,0 +1,36 @@
+package transport
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrDetached is returned for replies to a routed chat whose caller is gone.
+var ErrDetached = errors.New("reply target is no longer attached")
+
+type SendError struct {
+	Err        error
+	RetryAfter time.Duration
+	Permanent  bool
+}
+
+func (e *SendError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+func IsPermanent(err error) bool {
+	var sendErr *SendError
+	return errors.As(err, &sendErr) && sendErr.Permanent
+}
+
+func RetryAfter(err error) time.Duration {
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		return sendErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"html"
+	"strings"
+)
+
+// Labeled starts every message and caption with a line naming where it came
+// from, such as the host that ran the command. The label is escaped for the
+// message's parse mode.
+type Labeled struct {
+	next  Messenger
+	label string
+}
+
+func NewLabeled(next Messenger, label string) *Labeled {
+	return &Labeled{next: next, label: "[" + label + "]"}
+}
+
+func (l *Labeled) Send(msg Message) (int, error) {
+	return l.next.Send(l.labelMessage(msg))
+}
+
+func (l *Labeled) SendFile(file File) error {
+	file.Caption = strings.TrimSpace(l.label + " " + file.Caption)
+	return l.next.SendFile(file)
+}
+
+func (l *Labeled) Edit(messageID int, msg Message) error {
+	return l.next.Edit(messageID, l.labelMessage(msg))
+}
+
+func (l *Labeled) AnswerCallback(callbackID string, text string) error {
+	return l.next.AnswerCallback(callbackID, text)
+}
+
+func (l *Labeled) labelMessage(msg Message) Message {
+	label := l.label
+	switch msg.ParseMode {
+	case ModeHTML:
+		label = html.EscapeString(label)
+	case ModeMarkdownV2:
+		label = EscapeMarkdownV2(label)
+	case ModeMarkdown:
+		label = EscapeMarkdown(label)
+	}
+	msg.Text = label + "\n" + msg.Text
+	return msg
+}
@@ -0,0 +1,46 @@
+package transport
+
+import "testing"
+
+func TestLabeled(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"", "[web-1.lan]\nhello"},
+		{ModeHTML, "[web-1.lan]\nhello"},
+		{ModeMarkdownV2, "\\[web\\-1\\.lan\\]\nhello"},
+		{ModeMarkdown, "\\[web-1.lan]\nhello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			recorder := NewRecorder()
+			msg := NewMessage(1, "hello")
+			msg.ParseMode = tt.mode
+			NewLabeled(recorder, "web-1.lan").Send(msg)
+
+			if got := recorder.Messages()[0].Text; got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabeledEscapesHTML(t *testing.T) {
+	recorder := NewRecorder()
+	msg := NewMessage(1, "<b>hi</b>")
+	msg.ParseMode = ModeHTML
+	NewLabeled(recorder, "a<b>&").Send(msg)
+
+	if got, want := recorder.Messages()[0].Text, "[a&lt;b&gt;&amp;]\n<b>hi</b>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	in := `_*[]()~` + "`" + `>#+-=|{}.!\`
+	want := `\_\*\[\]\(\)\~\` + "`" + `\>\#\+\-\=\|\{\}\.\!\\`
+	if got := EscapeMarkdownV2(in); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,231 @@
+package transport
+
+import (
+	"fmt"
+	"log/slog"
+	"remoteadmin/metrics"
+	"sync"
+	"time"
+)
+
+type Limits struct {
+	ChatInterval   time.Duration
+	GlobalInterval time.Duration
+	MaxRetries     int
+	BaseBackoff    time.Duration
+}
+
+type sendResult struct {
+	messageID int
+	err       error
+}
+
+type outgoing struct {
+	chatID  int64
+	send    func() (int, error)
+	done    chan sendResult
+	attempt int
+}
+
+// RateLimited funnels every outgoing call through one queue. Calls for the
+// same chat are delivered in order and spaced by ChatInterval, all calls
+// together are spaced by GlobalInterval, and 429 or transient failures are
+// retried before the caller sees an error.
+type RateLimited struct {
+	next   Messenger
+	limits Limits
+
+	mu         sync.Mutex
+	items      []*outgoing
+	chatReady  map[int64]time.Time
+	busy       map[int64]bool
+	globalNext time.Time
+	inFlight   int
+	closed     bool
+	wake       chan struct{}
+	stopped    chan struct{}
+}
+
+func NewRateLimited(next Messenger, limits Limits) *RateLimited {
+	if limits.BaseBackoff <= 0 {
+		limits.BaseBackoff = time.Second
+	}
+
+	r := &RateLimited{
+		next:      next,
+		limits:    limits,
+		chatReady: make(map[int64]time.Time),
+		busy:      make(map[int64]bool),
+		wake:      make(chan struct{}, 1),
+		stopped:   make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *RateLimited) Send(msg Message) (int, error) {
+	return r.enqueue(msg.ChatID, func() (int, error) {
+		return r.next.Send(msg)
+	})
+}
+
+func (r *RateLimited) SendFile(file File) error {
+	_, err := r.enqueue(file.ChatID, func() (int, error) {
+		return 0, r.next.SendFile(file)
+	})
+	return err
+}
+
+func (r *RateLimited) Edit(messageID int, msg Message) error {
+	_, err := r.enqueue(msg.ChatID, func() (int, error) {
+		return 0, r.next.Edit(messageID, msg)
+	})
+	return err
+}
+
+// AnswerCallback skips the queue: Telegram expects an answer within seconds
+// and callback answers do not count towards message limits.
+func (r *RateLimited) AnswerCallback(callbackID string, text string) error {
+	return r.next.AnswerCallback(callbackID, text)
+}
+
+// Close stops accepting new sends and waits until the queue is empty.
+func (r *RateLimited) Close() {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.signal()
+
+	<-r.stopped
+}
+
+func (r *RateLimited) enqueue(chatID int64, send func() (int, error)) (int, error) {
+	item := &outgoing{
+		chatID: chatID,
+		send:   send,
+		done:   make(chan sendResult, 1),
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return 0, fmt.Errorf("send queue is closed")
+	}
+	r.items = append(r.items, item)
+	r.mu.Unlock()
+	r.signal()
+
+	result := <-item.done
+	return result.messageID, result.err
+}
+
+func (r *RateLimited) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *RateLimited) run() {
+	defer close(r.stopped)
+
+	for {
+		r.mu.Lock()
+		if r.closed && len(r.items) == 0 && r.inFlight == 0 {
+			r.mu.Unlock()
+			return
+		}
+
+		item, wait := r.nextReady(time.Now())
+		if item != nil {
+			r.busy[item.chatID] = true
+			r.inFlight++
+		}
+		r.mu.Unlock()
+
+		if item != nil {
+			go r.deliver(item)
+			continue
+		}
+
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = time.After(wait)
+		}
+
+		select {
+		case <-r.wake:
+		case <-timer:
+		}
+	}
+}
+
+// nextReady picks the oldest item whose chat is neither busy nor cooling
+// down. Without one, it reports how long until the earliest chat is ready.
+func (r *RateLimited) nextReady(now time.Time) (*outgoing, time.Duration) {
+	if now.Before(r.globalNext) {
+		return nil, r.globalNext.Sub(now)
+	}
+
+	var wait time.Duration
+	for i, item := range r.items {
+		if r.busy[item.chatID] {
+			continue
+		}
+
+		if ready := r.chatReady[item.chatID]; now.Before(ready) {
+			if d := ready.Sub(now); wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		r.items = append(r.items[:i], r.items[i+1:]...)
+		r.globalNext = now.Add(r.limits.GlobalInterval)
+		r.chatReady[item.chatID] = now.Add(r.limits.ChatInterval)
+		return item, 0
+	}
+
+	return nil, wait
+}
+
+func (r *RateLimited) deliver(item *outgoing) {
+	messageID, err := item.send()
+
+	r.mu.Lock()
+	delete(r.busy, item.chatID)
+	r.inFlight--
+
+	retry := err != nil && !IsPermanent(err) && item.attempt < r.limits.MaxRetries
+	if retry {
+		item.attempt++
+
+		delay := RetryAfter(err)
+		if delay <= 0 {
+			delay = r.limits.BaseBackoff << (item.attempt - 1)
+		}
+		r.chatReady[item.chatID] = time.Now().Add(delay)
+
+		// Put it back in front so later messages for this chat stay behind it.
+		r.items = append([]*outgoing{item}, r.items...)
+	}
+	// A retry may already be running once the lock is released.
+	attempt := item.attempt
+	r.mu.Unlock()
+	r.signal()
+
+	if retry {
+		slog.Debug("send failed, retrying", "chat_id", item.chatID, "attempt", attempt, "error", err)
+		return
+	}
+
+	if err != nil {
+		kind := "retries_exhausted"
+		if IsPermanent(err) {
+			kind = "permanent"
+		}
+		metrics.SendFailures.Inc(kind)
+		slog.Warn("send failed", "chat_id", item.chatID, "attempts", attempt+1, "permanent", IsPermanent(err), "error", err)
+	}
+	item.done <- sendResult{messageID: messageID, err: err}
+}
@@ -0,0 +1,89 @@
+package transport
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyMessenger fails the first failures sends with err.
+type flakyMessenger struct {
+	*Recorder
+	mu       sync.Mutex
+	failures int
+	err      error
+	attempts int
+}
+
+func (m *flakyMessenger) Send(msg Message) (int, error) {
+	m.mu.Lock()
+	m.attempts++
+	fail := m.attempts <= m.failures
+	m.mu.Unlock()
+
+	if fail {
+		return 0, m.err
+	}
+	return m.Recorder.Send(msg)
+}
+
+func TestRateLimitedRetries(t *testing.T) {
+	transient := &SendError{Err: errors.New("too many requests"), RetryAfter: time.Millisecond}
+	permanent := &SendError{Err: errors.New("chat not found"), Permanent: true}
+
+	tests := []struct {
+		name         string
+		failures     int
+		err          error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"first try", 0, nil, 1, false},
+		{"retried after 429", 2, transient, 3, false},
+		{"gives up after MaxRetries", 5, transient, 4, true},
+		{"permanent not retried", 1, permanent, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &flakyMessenger{Recorder: NewRecorder(), failures: tt.failures, err: tt.err}
+			r := NewRateLimited(next, Limits{MaxRetries: 3, BaseBackoff: time.Millisecond})
+			defer r.Close()
+
+			_, err := r.Send(NewMessage(1, "hi"))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if next.attempts != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", next.attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRateLimitedKeepsChatOrder(t *testing.T) {
+	recorder := NewRecorder()
+	r := NewRateLimited(recorder, Limits{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		text := string(rune('a' + i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Send(NewMessage(int64(i%2), text))
+		}()
+		// Let each send join the queue before the next one.
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+	r.Close()
+
+	last := map[int64]string{}
+	for _, msg := range recorder.Messages() {
+		if msg.Text < last[msg.ChatID] {
+			t.Fatalf("chat %d got %q after %q", msg.ChatID, msg.Text, last[msg.ChatID])
+		}
+		last[msg.ChatID] = msg.Text
+	}
+}
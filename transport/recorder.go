@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"os"
+	"sync"
+)
+
+type RecordedFile struct {
+	File
+	Data []byte
+}
+
+type RecordedEdit struct {
+	MessageID int
+	Message
+}
+
+type RecordedAnswer struct {
+	CallbackID string
+	Text       string
+}
+
+type Recorder struct {
+	mu       sync.Mutex
+	messages []Message
+	files    []RecordedFile
+	edits    []RecordedEdit
+	answers  []RecordedAnswer
+	nextID   int
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (r *Recorder) Send(msg Message) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	r.messages = append(r.messages, msg)
+	return r.nextID, nil
+}
+
+func (r *Recorder) SendFile(file File) error {
+	// Handlers delete their temp files right after sending, so keep a copy.
+	data, err := os.ReadFile(file.Path)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.files = append(r.files, RecordedFile{File: file, Data: data})
+	return nil
+}
+
+func (r *Recorder) Edit(messageID int, msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.edits = append(r.edits, RecordedEdit{MessageID: messageID, Message: msg})
+	return nil
+}
+
+func (r *Recorder) AnswerCallback(callbackID string, text string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.answers = append(r.answers, RecordedAnswer{CallbackID: callbackID, Text: text})
+	return nil
+}
+
+func (r *Recorder) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	messages := make([]Message, len(r.messages))
+	copy(messages, r.messages)
+	return messages
+}
+
+func (r *Recorder) Files() []RecordedFile {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	files := make([]RecordedFile, len(r.files))
+	copy(files, r.files)
+	return files
+}
+
+func (r *Recorder) Edits() []RecordedEdit {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	edits := make([]RecordedEdit, len(r.edits))
+	copy(edits, r.edits)
+	return edits
+}
+
+func (r *Recorder) Answers() []RecordedAnswer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	answers := make([]RecordedAnswer, len(r.answers))
+	copy(answers, r.answers)
+	return answers
+}
+
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = nil
+	r.files = nil
+	r.edits = nil
+	r.answers = nil
+}
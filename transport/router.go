@@ -0,0 +1,92 @@
+package transport
+
+import (
+	"math"
+	"sync"
+)
+
+// Router sends messages for attached chat IDs to their own messenger and
+// everything else to next. It lets callers outside Telegram, such as the
+// local API, run the same handlers and collect the replies.
+type Router struct {
+	next   Messenger
+	mu     sync.RWMutex
+	sinks  map[int64]Messenger
+	nextID int64
+}
+
+func NewRouter(next Messenger) *Router {
+	return &Router{
+		next:   next,
+		sinks:  make(map[int64]Messenger),
+		nextID: math.MinInt64,
+	}
+}
+
+// Attach returns a chat ID whose messages go to sink until detach is called.
+// The IDs start at the bottom of the int64 range, far from any Telegram chat.
+func (r *Router) Attach(sink Messenger) (chatID int64, detach func()) {
+	r.mu.Lock()
+	r.nextID++
+	chatID = r.nextID
+	r.sinks[chatID] = sink
+	r.mu.Unlock()
+
+	return chatID, func() {
+		r.mu.Lock()
+		delete(r.sinks, chatID)
+		r.mu.Unlock()
+	}
+}
+
+// Issued reports whether chatID came from Attach, even if it has since
+// been detached.
+func (r *Router) Issued(chatID int64) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return chatID <= r.nextID
+}
+
+// route picks the messenger for chatID. Replies that arrive after their
+// sink was detached, for example from a command that outlived its caller,
+// are dropped rather than sent to Telegram.
+func (r *Router) route(chatID int64) (Messenger, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if sink, ok := r.sinks[chatID]; ok {
+		return sink, nil
+	}
+	if chatID <= r.nextID {
+		return nil, ErrDetached
+	}
+	return r.next, nil
+}
+
+func (r *Router) Send(msg Message) (int, error) {
+	m, err := r.route(msg.ChatID)
+	if err != nil {
+		return 0, err
+	}
+	return m.Send(msg)
+}
+
+func (r *Router) SendFile(file File) error {
+	m, err := r.route(file.ChatID)
+	if err != nil {
+		return err
+	}
+	return m.SendFile(file)
+}
+
+func (r *Router) Edit(messageID int, msg Message) error {
+	m, err := r.route(msg.ChatID)
+	if err != nil {
+		return err
+	}
+	return m.Edit(messageID, msg)
+}
+
+func (r *Router) AnswerCallback(callbackID string, text string) error {
+	return r.next.AnswerCallback(callbackID, text)
+}
@@ -0,0 +1,31 @@
+package transport
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	telegram := NewRecorder()
+	sink := NewRecorder()
+	r := NewRouter(telegram)
+
+	chatID, detach := r.Attach(sink)
+	if !r.Issued(chatID) || r.Issued(42) {
+		t.Fatal("Issued doesn't tell attached IDs from Telegram chats")
+	}
+
+	r.Send(NewMessage(chatID, "to the caller"))
+	r.Send(NewMessage(42, "to telegram"))
+	if len(sink.Messages()) != 1 || len(telegram.Messages()) != 1 {
+		t.Fatalf("sink got %d, telegram got %d", len(sink.Messages()), len(telegram.Messages()))
+	}
+
+	detach()
+	if _, err := r.Send(NewMessage(chatID, "late")); !errors.Is(err, ErrDetached) {
+		t.Errorf("send after detach = %v, want ErrDetached", err)
+	}
+	if len(telegram.Messages()) != 1 {
+		t.Error("late reply leaked to telegram")
+	}
+}
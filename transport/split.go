@@ -0,0 +1,469 @@
+package transport
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// MaxMessageLength is Telegram's limit, counted in UTF-16 code units.
+	MaxMessageLength = 4096
+
+	// MaxMessageParts is how many messages one reply may be split into
+	// before it is sent as a .txt document instead.
+	MaxMessageParts = 5
+
+	// markupReserve leaves room for the markers that close and reopen
+	// entities at a split.
+	markupReserve = 32
+)
+
+// Splitter sends replies that are too long for one message as several
+// messages split at line boundaries, or as a text file when even that would
+// flood the chat. Markdown entities left open at a split are closed at the
+// end of one part and reopened at the start of the next.
+type Splitter struct {
+	next Messenger
+}
+
+func NewSplitter(next Messenger) *Splitter {
+	return &Splitter{next: next}
+}
+
+func (s *Splitter) Send(msg Message) (int, error) {
+	if textLength(msg.Text) <= MaxMessageLength {
+		return s.next.Send(msg)
+	}
+
+	parts := splitText(msg.Text, MaxMessageLength, msg.ParseMode)
+	if len(parts) > MaxMessageParts {
+		return s.sendAsDocument(msg)
+	}
+
+	var lastID int
+	for i, text := range parts {
+		part := msg
+		part.Text = text
+		if i < len(parts)-1 {
+			part.Keyboard = nil
+		}
+
+		id, err := s.next.Send(part)
+		if err != nil {
+			return lastID, err
+		}
+		lastID = id
+	}
+
+	return lastID, nil
+}
+
+func (s *Splitter) sendAsDocument(msg Message) (int, error) {
+	dir, err := os.MkdirTemp("", "remoteadmin-reply-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, fmt.Sprintf("reply_%s.txt", time.Now().Format("20060102_150405")))
+	if err := os.WriteFile(path, []byte(msg.Text), 0600); err != nil {
+		return 0, err
+	}
+
+	document := NewDocument(msg.ChatID, path)
+	document.Caption = fmt.Sprintf("Reply was too long for chat (%d characters)", textLength(msg.Text))
+	if err := s.next.SendFile(document); err != nil {
+		return 0, err
+	}
+
+	if len(msg.Keyboard) == 0 {
+		return 0, nil
+	}
+
+	// Buttons can't ride on a document, so they get their own message.
+	return s.next.Send(Message{
+		ChatID:   msg.ChatID,
+		Text:     "Full output is in the file above.",
+		Keyboard: msg.Keyboard,
+	})
+}
+
+func (s *Splitter) SendFile(file File) error {
+	return s.next.SendFile(file)
+}
+
+// Edit can't add messages, so an oversized edit keeps only its first part.
+func (s *Splitter) Edit(messageID int, msg Message) error {
+	if textLength(msg.Text) > MaxMessageLength {
+		msg.Text = splitText(msg.Text, MaxMessageLength, msg.ParseMode)[0]
+	}
+	return s.next.Edit(messageID, msg)
+}
+
+func (s *Splitter) AnswerCallback(callbackID, text string) error {
+	return s.next.AnswerCallback(callbackID, text)
+}
+
+func textLength(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// splitText breaks text into parts of at most limit characters. It cuts
+// after a newline where it can, then after a space, and never inside a
+// rune, an escape, a multi-character marker, a link, or an HTML tag or
+// entity. Entities left open at a cut are closed at the end of the part and
+// reopened at the start of the next.
+func splitText(text string, limit int, parseMode string) []string {
+	var scan func(text string, open []string) []string
+	var openMarkers, closeMarkers func(open []string) string
+	var visible func(body string) bool
+	unsafe := make([]uint8, len(text)+1)
+
+	switch parseMode {
+	case ModeMarkdown, ModeMarkdownV2:
+		v2 := parseMode == ModeMarkdownV2
+		scan = func(text string, open []string) []string {
+			return scanMarkdown(text, open, v2, nil)
+		}
+		openMarkers, closeMarkers, visible = openMarkdown, closeMarkdown, visibleMarkdown
+		scanMarkdown(text, nil, v2, unsafe)
+	case ModeHTML:
+		scan, openMarkers, closeMarkers, visible = scanHTML, openHTML, closeHTML, visibleHTML
+		protectHTML(text, unsafe)
+	}
+
+	var parts []string
+	var open []string
+	for pos := 0; pos < len(text); {
+		prefix := ""
+		budget := limit
+		if scan != nil {
+			prefix = openMarkers(open)
+			budget -= textLength(prefix) + markupReserve
+		}
+
+		end := cutPoint(text, pos, max(budget, 1), unsafe)
+		body := strings.TrimRight(text[pos:end], "\n")
+		pos = end
+		if strings.TrimSpace(body) == "" {
+			continue
+		}
+
+		if scan == nil {
+			parts = append(parts, body)
+			continue
+		}
+		open = scan(body, open)
+		// A part that would only open or close entities, such as the
+		// closing ``` of a code block, adds nothing the neighbouring parts
+		// don't already say, and Telegram refuses empty messages.
+		if !visible(body) {
+			continue
+		}
+		parts = append(parts, prefix+body+closeMarkers(open))
+	}
+
+	return parts
+}
+
+// How bad a cut at a position would be. A link longer than a whole part
+// has to be cut somewhere, but never inside an escape, marker, tag or
+// entity.
+const (
+	cutSafe uint8 = iota
+	cutInLink
+	cutBreaks
+)
+
+// cutPoint returns where the part starting at pos should end so it holds
+// at most budget characters: after the last newline that fits, else after
+// the last space, else at the last safe position. Only a run with no safe
+// position at all, such as a link longer than budget, is cut elsewhere.
+func cutPoint(text string, pos, budget int, unsafe []uint8) int {
+	n := 0
+	afterNewline, afterSpace, safe, inLink, anyRune := -1, -1, -1, -1, -1
+	for i, r := range text[pos:] {
+		if n+utf16.RuneLen(r) > budget {
+			break
+		}
+		n += utf16.RuneLen(r)
+
+		end := pos + i + utf8.RuneLen(r)
+		if end == len(text) {
+			return end
+		}
+		anyRune = end
+		switch unsafe[end] {
+		case cutBreaks:
+			continue
+		case cutInLink:
+			inLink = end
+			continue
+		}
+		safe = end
+		switch r {
+		case '\n':
+			afterNewline = end
+		case ' ', '\t':
+			afterSpace = end
+		}
+	}
+
+	for _, cut := range []int{afterNewline, afterSpace, safe, inLink, anyRune} {
+		if cut <= pos {
+			continue
+		}
+		// Take a newline right after the cut along, rather than starting
+		// the next part with it.
+		if text[cut] == '\n' && unsafe[cut+1] == cutSafe {
+			cut++
+		}
+		return cut
+	}
+	// Not even one rune fits; take it anyway so the split moves on.
+	_, size := utf8.DecodeRuneInString(text[pos:])
+	return pos + size
+}
+
+// markInside flags the positions strictly between from and to, where a
+// cut would break something apart.
+func markInside(unsafe []uint8, from, to int, how uint8) {
+	if unsafe == nil {
+		return
+	}
+	for p := from + 1; p < to && p < len(unsafe); p++ {
+		unsafe[p] = max(unsafe[p], how)
+	}
+}
+
+// scanMarkdown follows Telegram's Markdown through text, starting with the
+// entities in open, and returns the entities still open at the end. v2
+// adds MarkdownV2's underline, strikethrough and spoiler. Legacy Markdown
+// has no escapes inside entities, so a backslash in a code block, such as
+// in a Windows path, is plain text there. When unsafe is set it also flags
+// the positions a cut must avoid: inside escapes, multi-character markers
+// and links.
+func scanMarkdown(text string, open []string, v2 bool, unsafe []uint8) []string {
+	stack := append([]string(nil), open...)
+	top := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		return stack[len(stack)-1]
+	}
+	toggle := func(marker string) {
+		if top() == marker {
+			stack = stack[:len(stack)-1]
+		} else {
+			stack = append(stack, marker)
+		}
+	}
+
+	// A link's URL holds no entities; the scan jumps from the end of the
+	// link text to the end of the link.
+	urlStart, linkEnd := -1, -1
+
+	for i := 0; i < len(text); i++ {
+		if i == urlStart {
+			i = linkEnd - 1
+			urlStart = -1
+			continue
+		}
+
+		current := top()
+		inCode := current == "```" || current == "`"
+
+		switch {
+		case text[i] == '\\' && (v2 || current == ""):
+			_, size := utf8.DecodeRuneInString(text[i+1:])
+			markInside(unsafe, i, i+1+size, cutBreaks)
+			i += size
+		case inCode:
+			if current == "```" && strings.HasPrefix(text[i:], "```") {
+				markInside(unsafe, i, i+3, cutBreaks)
+				stack = stack[:len(stack)-1]
+				i += 2
+			} else if current == "`" && text[i] == '`' {
+				stack = stack[:len(stack)-1]
+			}
+		case strings.HasPrefix(text[i:], "```"):
+			markInside(unsafe, i, i+3, cutBreaks)
+			stack = append(stack, "```")
+			i += 2
+		case text[i] == '`':
+			stack = append(stack, "`")
+		case text[i] == '[':
+			if textEnd, end := findLink(text, i); end != -1 {
+				markInside(unsafe, i, end, cutInLink)
+				urlStart, linkEnd = textEnd, end
+			}
+		case v2 && (strings.HasPrefix(text[i:], "__") || strings.HasPrefix(text[i:], "||")):
+			markInside(unsafe, i, i+2, cutBreaks)
+			toggle(text[i : i+2])
+			i++
+		case text[i] == '*' || text[i] == '_' || v2 && text[i] == '~':
+			toggle(string(text[i]))
+		}
+	}
+
+	return stack
+}
+
+// findLink finds the end of a [text](url) link starting at i: the index of
+// the "](" and the index just past the closing ")". It returns -1s if
+// there is no such link.
+func findLink(text string, i int) (int, int) {
+	textEnd := -1
+	for j := i + 1; j < len(text); j++ {
+		switch {
+		case text[j] == '\\':
+			j++
+		case textEnd == -1 && strings.HasPrefix(text[j:], "]("):
+			textEnd = j
+			j++
+		case textEnd != -1 && text[j] == ')':
+			return textEnd, j + 1
+		}
+	}
+	return -1, -1
+}
+
+// visibleMarkdown reports whether body has anything besides markers and
+// whitespace.
+func visibleMarkdown(body string) bool {
+	return strings.Trim(body, "`*_~| \t\n") != ""
+}
+
+func openMarkdown(open []string) string {
+	var markers strings.Builder
+	for _, marker := range open {
+		markers.WriteString(marker)
+		if marker == "```" {
+			markers.WriteString("\n")
+		}
+	}
+	return markers.String()
+}
+
+func closeMarkdown(open []string) string {
+	var markers strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		if open[i] == "```" {
+			markers.WriteString("\n")
+		}
+		markers.WriteString(open[i])
+	}
+	return markers.String()
+}
+
+// scanHTML tracks open tags the same way. Entries are the full opening tags
+// so attributes such as a link's href survive reopening.
+func scanHTML(text string, open []string) []string {
+	stack := append([]string(nil), open...)
+
+	for {
+		start := strings.IndexByte(text, '<')
+		if start == -1 {
+			return stack
+		}
+		end := strings.IndexByte(text[start:], '>')
+		if end == -1 {
+			return stack
+		}
+
+		tag := text[start : start+end+1]
+		text = text[start+end+1:]
+
+		if strings.HasPrefix(tag, "</") {
+			name := tagName(tag)
+			for i := len(stack) - 1; i >= 0; i-- {
+				if tagName(stack[i]) == name {
+					stack = append(stack[:i], stack[i+1:]...)
+					break
+				}
+			}
+			continue
+		}
+		stack = append(stack, tag)
+	}
+}
+
+// visibleHTML reports whether body has anything besides tags and
+// whitespace.
+func visibleHTML(body string) bool {
+	for {
+		start := strings.IndexByte(body, '<')
+		if start == -1 {
+			return strings.TrimSpace(body) != ""
+		}
+		if strings.TrimSpace(body[:start]) != "" {
+			return true
+		}
+		end := strings.IndexByte(body[start:], '>')
+		if end == -1 {
+			return true
+		}
+		body = body[start+end+1:]
+	}
+}
+
+// protectHTML flags the positions inside tags and entities, where a cut
+// would leave broken markup.
+func protectHTML(text string, unsafe []uint8) {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '<':
+			if end := strings.IndexByte(text[i:], '>'); end != -1 {
+				markInside(unsafe, i, i+end+1, cutBreaks)
+				i += end
+			}
+		case '&':
+			if end := strings.IndexByte(text[i:], ';'); end > 1 && end <= maxEntityLength && isEntityName(text[i+1:i+end]) {
+				markInside(unsafe, i, i+end+1, cutBreaks)
+				i += end
+			}
+		}
+	}
+}
+
+// maxEntityLength covers the longest entity Telegram knows, such as
+// "&#x1F600;", with room to spare.
+const maxEntityLength = 12
+
+func isEntityName(name string) bool {
+	for _, r := range name {
+		if !(r == '#' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func tagName(tag string) string {
+	name := strings.Trim(tag, "</>")
+	if i := strings.IndexAny(name, " \t\n"); i != -1 {
+		name = name[:i]
+	}
+	return strings.ToLower(name)
+}
+
+func openHTML(open []string) string {
+	return strings.Join(open, "")
+}
+
+func closeHTML(open []string) string {
+	var tags strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		tags.WriteString("</" + tagName(open[i]) + ">")
+	}
+	return tags.String()
+}
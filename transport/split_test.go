@@ -0,0 +1,248 @@
+package transport
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitText(t *testing.T) {
+	// A limit of 40 leaves 8 characters per part once markupReserve is
+	// set aside for markup modes.
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		mode  string
+		want  []string
+	}{
+		{
+			name:  "line boundaries",
+			text:  "one\ntwo\nthree\nfour",
+			limit: 9,
+			want:  []string{"one\ntwo", "three", "four"},
+		},
+		{
+			name:  "long line at a space",
+			text:  "aaaa bbbb cccc",
+			limit: 10,
+			want:  []string{"aaaa bbbb ", "cccc"},
+		},
+		{
+			name:  "long word at a rune",
+			text:  "ééééé",
+			limit: 2,
+			want:  []string{"éé", "éé", "é"},
+		},
+		{
+			name:  "never between surrogates",
+			text:  "😀😀😀",
+			limit: 3,
+			want:  []string{"😀", "😀", "😀"},
+		},
+		{
+			name:  "bold reopened",
+			text:  "*aaaa\nbbbb*",
+			limit: 40,
+			mode:  ModeMarkdownV2,
+			want:  []string{"*aaaa*", "*bbbb*"},
+		},
+		{
+			name:  "escape kept whole",
+			text:  `aaaaaaa\.b`,
+			limit: 40,
+			mode:  ModeMarkdownV2,
+			want:  []string{`aaaaaaa`, `\.b`},
+		},
+		{
+			name:  "strikethrough reopened",
+			text:  "~aaa bbb ccc~",
+			limit: 40,
+			mode:  ModeMarkdownV2,
+			want:  []string{"~aaa ~", "~bbb ~", "~ccc~"},
+		},
+		{
+			name:  "spoiler marker kept whole",
+			text:  "aaaaaaa||b||",
+			limit: 40,
+			mode:  ModeMarkdownV2,
+			want:  []string{"aaaaaaa", "||b||"},
+		},
+		{
+			name:  "underline reopened",
+			text:  "__aaa bbb__",
+			limit: 40,
+			mode:  ModeMarkdownV2,
+			want:  []string{"__aaa __", "__bbb__"},
+		},
+		{
+			name:  "link kept whole",
+			text:  "aa [bb](u) c",
+			limit: 40,
+			mode:  ModeMarkdownV2,
+			want:  []string{"aa ", "[bb](u) ", "c"},
+		},
+		{
+			name:  "legacy markdown leaves tildes alone",
+			text:  "~aaa bb c",
+			limit: 40,
+			mode:  ModeMarkdown,
+			want:  []string{"~aaa bb ", "c"},
+		},
+		{
+			name:  "pre reopened on its own line",
+			text:  "```\nline1\nline2\n```",
+			limit: 44,
+			mode:  ModeMarkdownV2,
+			want:  []string{"```\nline1\n```", "```\nline2\n```"},
+		},
+		{
+			name:  "escape kept whole inside a long link",
+			text:  `[aaaaa\]bbbbbbb](u)`,
+			limit: 40,
+			mode:  ModeMarkdownV2,
+			want:  []string{`[aaaaa\]`, `bbbbbbb]`, `(u)`},
+		},
+		{
+			name:  "html entity kept whole",
+			text:  "aaaaaaa&amp;b",
+			limit: 40,
+			mode:  ModeHTML,
+			want:  []string{"aaaaaaa", "&amp;b"},
+		},
+		{
+			name:  "html tag kept whole and reopened",
+			text:  "<b>aaa bbb</b>",
+			limit: 43,
+			mode:  ModeHTML,
+			want:  []string{"<b>aaa </b>", "<b>bbb</b>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitText(tt.text, tt.limit, tt.mode)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSplitTextIsValid splits long generated replies at every limit in a
+// range and checks each part could be sent on its own.
+func TestSplitTextIsValid(t *testing.T) {
+	tests := []struct {
+		mode  string
+		piece string
+	}{
+		{"", "plain ünïcödé 😀 text "},
+		{ModeMarkdownV2, `*bold* \. _it_ ~s~ ||sp|| __u__ [link \] x](http://e\.x/\)) ` + "`c\\`` 😀 "},
+		{ModeMarkdown, "*bold* _it_ [link](http://e.x/) `code` \\_ é "},
+		{ModeHTML, `<b>bold</b> &amp; &#x1F600; <a href="http://e.x/?a=1&amp;b=2">link</a> <i>é</i> `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			text := strings.Repeat(tt.piece, 40)
+			// Start above the longest link or tag in the pieces, plus the
+			// markup reopened around it.
+			for limit := 100; limit < 180; limit++ {
+				for _, part := range splitText(text, limit, tt.mode) {
+					checkPart(t, part, limit, tt.mode)
+				}
+			}
+		})
+	}
+}
+
+// Legacy Markdown has no escapes in code, so paths ending in a backslash
+// must not keep a code block open past its closing backticks.
+func TestSplitLegacyCodeWithWindowsPaths(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("```\n")
+	for i := range 400 {
+		fmt.Fprintf(&b, "C:\\Users\\admin\\AppData\\Local\\Temp\\run%d\\\n", i)
+	}
+	b.WriteString("C:\\Windows\\```\nDone in `C:\\tmp\\`.")
+
+	parts := splitText(b.String(), MaxMessageLength, ModeMarkdown)
+	if len(parts) < 2 {
+		t.Fatalf("got %d parts, want a split", len(parts))
+	}
+	for i, part := range parts {
+		checkPart(t, part, MaxMessageLength, ModeMarkdown)
+		if !strings.HasPrefix(part, "```") {
+			t.Errorf("part %d doesn't reopen the code block: %q", i, part[:20])
+		}
+	}
+	if last := parts[len(parts)-1]; !strings.HasSuffix(last, "Done in `C:\\tmp\\`.") {
+		t.Errorf("last part ends %q", last[len(last)-40:])
+	}
+}
+
+func checkPart(t *testing.T, part string, limit int, mode string) {
+	t.Helper()
+
+	switch {
+	case textLength(part) > limit:
+		t.Fatalf("limit %d: part is %d long: %q", limit, textLength(part), part)
+	case !utf8.ValidString(part):
+		t.Fatalf("limit %d: part is not valid UTF-8: %q", limit, part)
+	}
+
+	switch mode {
+	case ModeMarkdown, ModeMarkdownV2:
+		if open := scanMarkdown(part, nil, mode == ModeMarkdownV2, nil); len(open) != 0 {
+			t.Fatalf("limit %d: %v left open in %q", limit, open, part)
+		}
+		trailing := len(part) - len(strings.TrimRight(part, `\`))
+		if trailing%2 == 1 {
+			t.Fatalf("limit %d: part ends in a lone backslash: %q", limit, part)
+		}
+		if strings.Count(part, "](") != strings.Count(part, "[")-strings.Count(part, `\[`) {
+			t.Fatalf("limit %d: link cut apart in %q", limit, part)
+		}
+	case ModeHTML:
+		if open := scanHTML(part, nil); len(open) != 0 {
+			t.Fatalf("limit %d: %v left open in %q", limit, open, part)
+		}
+		if strings.Count(part, "<") != strings.Count(part, ">") {
+			t.Fatalf("limit %d: tag cut apart in %q", limit, part)
+		}
+		if strings.Count(part, "&") != strings.Count(part, ";") {
+			t.Fatalf("limit %d: entity cut apart in %q", limit, part)
+		}
+	}
+}
+
+func TestSplitterSendsParts(t *testing.T) {
+	recorder := NewRecorder()
+	text := strings.Repeat("x", MaxMessageLength) + "\n" + "tail"
+	msg := NewMessage(1, text)
+	msg.Keyboard = NewKeyboard(NewRow(NewButton("ok", "ok")))
+
+	if _, err := NewSplitter(recorder).Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	messages := recorder.Messages()
+	if len(messages) != 2 || messages[1].Text != "tail" {
+		t.Fatalf("sent %d messages", len(messages))
+	}
+	if messages[0].Keyboard != nil || messages[1].Keyboard == nil {
+		t.Error("buttons should only be on the last part")
+	}
+}
+
+func TestSplitterFallsBackToDocument(t *testing.T) {
+	recorder := NewRecorder()
+	text := strings.Repeat(strings.Repeat("y", 100)+"\n", (MaxMessageParts+1)*MaxMessageLength/100)
+
+	if _, err := NewSplitter(recorder).Send(NewMessage(1, text)); err != nil {
+		t.Fatal(err)
+	}
+	files := recorder.Files()
+	if len(recorder.Messages()) != 0 || len(files) != 1 || string(files[0].Data) != text {
+		t.Errorf("messages %d, files %d", len(recorder.Messages()), len(files))
+	}
+}
@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type Telegram struct {
+	api *tgbotapi.BotAPI
+}
+
+func NewTelegram(api *tgbotapi.BotAPI) *Telegram {
+	return &Telegram{
+		api: api,
+	}
+}
+
+func (t *Telegram) Send(msg Message) (int, error) {
+	config := tgbotapi.NewMessage(msg.ChatID, msg.Text)
+	config.ParseMode = msg.ParseMode
+	if len(msg.Keyboard) > 0 {
+		config.ReplyMarkup = inlineKeyboard(msg.Keyboard)
+	}
+
+	sent, err := t.api.Send(config)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	return sent.MessageID, nil
+}
+
+func (t *Telegram) Edit(messageID int, msg Message) error {
+	config := tgbotapi.NewEditMessageText(msg.ChatID, messageID, msg.Text)
+	config.ParseMode = msg.ParseMode
+	if len(msg.Keyboard) > 0 {
+		markup := inlineKeyboard(msg.Keyboard)
+		config.ReplyMarkup = &markup
+	}
+
+	_, err := t.api.Request(config)
+	return wrapError(err)
+}
+
+func (t *Telegram) AnswerCallback(callbackID string, text string) error {
+	_, err := t.api.Request(tgbotapi.NewCallback(callbackID, text))
+	return wrapError(err)
+}
+
+// wrapError classifies Telegram API errors so the send queue knows whether
+// to wait, retry or give up. Network errors are left as they are and count
+// as transient.
+func wrapError(err error) error {
+	var apiErr *tgbotapi.Error
+	if err == nil || !errors.As(err, &apiErr) {
+		return err
+	}
+
+	switch {
+	case apiErr.Code == http.StatusTooManyRequests:
+		return &SendError{Err: err, RetryAfter: time.Duration(apiErr.RetryAfter) * time.Second}
+	case apiErr.Code >= 500:
+		return &SendError{Err: err}
+	default:
+		return &SendError{Err: err, Permanent: true}
+	}
+}
+
+func inlineKeyboard(keyboard Keyboard) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, row := range keyboard {
+		var buttons []tgbotapi.InlineKeyboardButton
+		for _, button := range row {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(button.Text, button.Data))
+		}
+		rows = append(rows, buttons)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func (t *Telegram) SendFile(file File) error {
+	data := tgbotapi.FilePath(file.Path)
+
+	var config tgbotapi.Chattable
+	switch file.Kind {
+	case KindPhoto:
+		photo := tgbotapi.NewPhoto(file.ChatID, data)
+		photo.Caption = file.Caption
+		config = photo
+	case KindDocument:
+		document := tgbotapi.NewDocument(file.ChatID, data)
+		document.Caption = file.Caption
+		config = document
+	case KindAudio:
+		audio := tgbotapi.NewAudio(file.ChatID, data)
+		audio.Caption = file.Caption
+		config = audio
+	case KindVideo:
+		video := tgbotapi.NewVideo(file.ChatID, data)
+		video.Caption = file.Caption
+		config = video
+	default:
+		return fmt.Errorf("unsupported file kind: %s", file.Kind)
+	}
+
+	_, err := t.api.Send(config)
+	return wrapError(err)
+}
+
+func (t *Telegram) Download(fileID string, dst string) error {
+	fileURL, err := t.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Get(fileURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	localFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer localFile.Close()
+
+	_, err = io.Copy(localFile, resp.Body)
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return nil
+}
+
+func (t *Telegram) UserName() string {
+	return t.api.Self.UserName
+}
@@ -0,0 +1,112 @@
+package transport
+
+const (
+	ModeMarkdown   = "Markdown"
+	ModeMarkdownV2 = "MarkdownV2"
+	ModeHTML       = "HTML"
+)
+
+type Button struct {
+	Text string
+	Data string
+}
+
+type Keyboard [][]Button
+
+func NewButton(text, data string) Button {
+	return Button{Text: text, Data: data}
+}
+
+func NewKeyboard(rows ...[]Button) Keyboard {
+	return Keyboard(rows)
+}
+
+func NewRow(buttons ...Button) []Button {
+	return buttons
+}
+
+type Message struct {
+	ChatID    int64
+	Text      string
+	ParseMode string
+	Keyboard  Keyboard
+}
+
+func NewMessage(chatID int64, text string) Message {
+	return Message{
+		ChatID: chatID,
+		Text:   text,
+	}
+}
+
+func NewMarkdownMessage(chatID int64, text string) Message {
+	return Message{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: ModeMarkdown,
+	}
+}
+
+type FileKind int
+
+const (
+	KindPhoto FileKind = iota
+	KindDocument
+	KindAudio
+	KindVideo
+)
+
+func (k FileKind) String() string {
+	switch k {
+	case KindPhoto:
+		return "photo"
+	case KindDocument:
+		return "document"
+	case KindAudio:
+		return "audio"
+	case KindVideo:
+		return "video"
+	default:
+		return "unknown"
+	}
+}
+
+type File struct {
+	ChatID  int64
+	Kind    FileKind
+	Path    string
+	Caption string
+}
+
+func NewPhoto(chatID int64, path string) File {
+	return File{ChatID: chatID, Kind: KindPhoto, Path: path}
+}
+
+func NewDocument(chatID int64, path string) File {
+	return File{ChatID: chatID, Kind: KindDocument, Path: path}
+}
+
+func NewAudio(chatID int64, path string) File {
+	return File{ChatID: chatID, Kind: KindAudio, Path: path}
+}
+
+func NewVideo(chatID int64, path string) File {
+	return File{ChatID: chatID, Kind: KindVideo, Path: path}
+}
+
+type Attachment struct {
+	FileID   string
+	FileName string
+	MimeType string
+}
+
+type Messenger interface {
+	Send(msg Message) (int, error)
+	SendFile(file File) error
+	Edit(messageID int, msg Message) error
+	AnswerCallback(callbackID string, text string) error
+}
+
+type Downloader interface {
+	Download(fileID string, dst string) error
+}